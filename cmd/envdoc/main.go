@@ -0,0 +1,99 @@
+// envdoc 按 env 标签生成环境变量文档 (markdown, .env 示例, json)
+//
+//	envdoc [-target rotate|audit|notify] [-prefix app_] [-suffix _logger] [-case upper|lower|normal] [-format markdown|env|json] [-o file]
+//
+// 未指定 -prefix 时使用目标的默认前缀: audit 为 audit, notify 为 notify;
+// -values 输出当前生效的配置 (标签默认值 + 环境变量), 格式为 env, json, yaml 或 k8s, secret 字段打码
+//
+//	envdoc -target rotate -values -format k8s
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/weblfe/logrus_hooks/notify"
+	"github.com/weblfe/logrus_hooks/rotate"
+	"github.com/weblfe/logrus_hooks/utils"
+)
+
+type target struct {
+	prefix  string
+	options func() interface{}
+}
+
+var (
+	targets = map[string]target{
+		"rotate": {options: func() interface{} { return new(rotate.Options) }},
+		"audit":  {prefix: rotate.AuditEnvPrefix, options: func() interface{} { return new(rotate.Options) }},
+		"notify": {prefix: "notify", options: func() interface{} { return new(notify.Options) }},
+	}
+	caseModes = map[string]utils.CaseMode{
+		"upper":  utils.UpperCase,
+		"lower":  utils.LowerCase,
+		"normal": utils.NormalCase,
+	}
+)
+
+func main() {
+	var (
+		name     = flag.String("target", "rotate", "options to document: rotate, audit or notify")
+		prefix   = flag.String("prefix", "", "environment variable prefix, defaults to the target prefix")
+		suffix   = flag.String("suffix", "", "environment variable suffix")
+		caseName = flag.String("case", "upper", "environment variable case: upper, lower or normal")
+		format   = flag.String("format", utils.DocMarkdown, "output format: markdown, env or json")
+		output   = flag.String("o", "", "output file, defaults to stdout")
+		values   = flag.Bool("values", false, "print effective values (tag defaults and env) as env, json, yaml or k8s")
+	)
+	flag.Parse()
+	var item, ok = targets[strings.ToLower(*name)]
+	if !ok {
+		exit(fmt.Errorf("unknown target %q", *name))
+	}
+	caseMode, ok := caseModes[strings.ToLower(*caseName)]
+	if !ok {
+		exit(fmt.Errorf("unknown case %q", *caseName))
+	}
+	if *prefix == "" {
+		*prefix = item.prefix
+	}
+	var (
+		decoder           = utils.NewEnvDecoder(caseMode).SetPrefix(*prefix).SetSuffix(*suffix)
+		options           = item.options()
+		out     io.Writer = os.Stdout
+		err     error
+	)
+	if *output != "" {
+		var file *os.File
+		if file, err = os.Create(*output); err != nil {
+			exit(err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if *values {
+		err = decoder.Marshal(options)
+		if err == nil {
+			if *format == utils.DocMarkdown {
+				*format = utils.EncodeEnv
+			}
+			err = utils.NewEnvEncoder(caseMode).SetPrefix(*prefix).SetSuffix(*suffix).Write(out, options, *format)
+		}
+	} else {
+		var docs []*utils.EnvDoc
+		if docs, err = decoder.Describe(options); err == nil {
+			err = utils.WriteEnvDocs(out, docs, *format)
+		}
+	}
+	if err != nil {
+		exit(err)
+	}
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "envdoc:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,53 @@
+// logaudit 校验审计日志哈希链
+//
+//	logaudit [-prefix audit] [segment ...]
+//
+// 未指定分段时按 <PREFIX>_LOG_NAME / <PREFIX>_LOG_NAME_LAYOUT 等环境变量查找分段, 加密分段使用 <PREFIX>_ENCRYPT_KEY(_FILE) 解密
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/weblfe/logrus_hooks/rotate"
+	"github.com/weblfe/logrus_hooks/utils"
+)
+
+func main() {
+	var (
+		prefix = flag.String("prefix", rotate.AuditEnvPrefix, "environment variable prefix of audit options")
+		asJson = flag.Bool("json", false, "print report as json")
+		report *rotate.AuditReport
+		err    error
+	)
+	flag.Parse()
+	options, err := rotate.CreateOptionsWithEnvE(utils.UpperCase, *prefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit options:", err)
+		os.Exit(1)
+	}
+	if flag.NArg() > 0 {
+		var keys *rotate.KeyRing
+		if keys, err = options.GetKeyRing(); err == nil {
+			report, err = rotate.VerifyAuditFiles(keys, flag.Args()...)
+		}
+	} else {
+		report, err = rotate.VerifyAudit(options)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit verify failed:", err)
+		os.Exit(1)
+	}
+	if *asJson {
+		var bytes, _ = json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(bytes))
+		return
+	}
+	for _, v := range report.Segments {
+		fmt.Println("ok", v)
+	}
+	fmt.Printf("verified %d lines in %d segments, anchor %s, last chain %s\n",
+		report.Lines, len(report.Segments), report.Anchor, report.LastChain)
+}
@@ -0,0 +1,62 @@
+// logcat 输出日志分段明文, 自动解压 .gz 与解密加密分段, 可配合 tail/grep 使用
+//
+//	logcat [-prefix app] [-key-file keys.txt] segment ...
+//
+// 密钥默认读取 <PREFIX>_ENCRYPT_KEY 或 <PREFIX>_ENCRYPT_KEY_FILE 环境变量
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/weblfe/logrus_hooks/rotate"
+	"github.com/weblfe/logrus_hooks/utils"
+)
+
+func main() {
+	var (
+		prefix  = flag.String("prefix", "", "environment variable prefix of rotate options")
+		keyFile = flag.String("key-file", "", "encryption key file, overrides environment variables")
+	)
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: logcat [-prefix app] [-key-file keys.txt] segment ...")
+		os.Exit(2)
+	}
+	var options, err = rotate.CreateOptionsWithEnvE(utils.UpperCase, *prefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "logcat:", err)
+		os.Exit(1)
+	}
+	if *keyFile != "" {
+		options.EncryptKey, options.EncryptKeyFile = "", *keyFile
+	}
+	keys, err := options.GetKeyRing()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "logcat:", err)
+		os.Exit(1)
+	}
+	for _, name := range flag.Args() {
+		if err = cat(name, keys); err != nil {
+			fmt.Fprintln(os.Stderr, "logcat:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func cat(name string, keys *rotate.KeyRing) error {
+	var reader, err = rotate.OpenSegment(name, keys)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if _, err = io.Copy(os.Stdout, reader); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if rotate.IsUnsealed(reader) {
+		fmt.Fprintf(os.Stderr, "logcat: %s: encrypted segment not sealed (still being written or writer exited abnormally)\n", name)
+	}
+	return nil
+}
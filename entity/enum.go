@@ -3,6 +3,7 @@ package entity
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 )
 
@@ -10,12 +11,13 @@ type (
 	// Symbol 符号类型
 	Symbol string
 
-	// Enum 枚举类型
+	// Enum 枚举类型; 作为配置字段解码时需以 EnumMgr.Zero() 初始化以确定类型, 零值 Enum 无法解码
 	Enum struct {
 		value   interface{} // 枚举值
 		desc    string      // 枚举描述
 		symbol  Symbol      // 枚举类型
 		mapping interface{} // 枚举自定义映射值
+		aliases []string    // 枚举别名
 	}
 
 	// enumLists 枚举列表
@@ -35,11 +37,19 @@ type (
 		Value   interface{}
 		Mapping interface{}
 		Desc    string
+		Aliases []string `json:",omitempty"`
+	}
+
+	// enumRegistry 按类型登记的枚举列表, 供 UnmarshalText 按 symbol 查找
+	enumRegistry struct {
+		locker sync.RWMutex
+		mgrs   map[Symbol]*EnumMgr
 	}
 )
 
 var (
 	nullEnum = NewEnum(nil, "nil", "nil enums")
+	enums    = &enumRegistry{mgrs: make(map[Symbol]*EnumMgr)}
 )
 
 func NewEnum(v interface{}, symbol Symbol, desc ...string) *Enum {
@@ -51,6 +61,14 @@ func NewEnum(v interface{}, symbol Symbol, desc ...string) *Enum {
 	return enum
 }
 
+// GetEnumMgr 按类型获取已创建的枚举列表
+func GetEnumMgr(symbol Symbol) (*EnumMgr, bool) {
+	enums.locker.RLock()
+	defer enums.locker.RUnlock()
+	var mgr, ok = enums.mgrs[symbol]
+	return mgr, ok
+}
+
 func (enum *Enum) Symbol() Symbol {
 	return enum.symbol
 }
@@ -84,6 +102,97 @@ func (enum *Enum) GetCustom() interface{} {
 	return enum.mapping
 }
 
+// SetAlias 设置别名, 查找时与值, 描述一样不区分大小写
+func (enum *Enum) SetAlias(aliases ...string) *Enum {
+	if enum == nil {
+		return nil
+	}
+	enum.aliases = append(enum.aliases, aliases...)
+	return enum
+}
+
+func (enum *Enum) Value() interface{} {
+	if enum == nil {
+		return nil
+	}
+	return enum.value
+}
+
+func (enum *Enum) Desc() string {
+	if enum == nil {
+		return ""
+	}
+	return enum.desc
+}
+
+func (enum *Enum) Aliases() []string {
+	if enum == nil {
+		return nil
+	}
+	return enum.aliases
+}
+
+// Match 名称是否匹配枚举值, 描述或别名, 不区分大小写
+func (enum *Enum) Match(name string) bool {
+	if enum == nil {
+		return false
+	}
+	name = strings.TrimSpace(name)
+	if strings.EqualFold(fmt.Sprintf("%v", enum.value), name) || strings.EqualFold(enum.desc, name) {
+		return true
+	}
+	for _, v := range enum.aliases {
+		if strings.EqualFold(v, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalText 输出枚举值
+func (enum Enum) MarshalText() ([]byte, error) {
+	if enum.value == nil {
+		return []byte{}, nil
+	}
+	return []byte(fmt.Sprintf("%v", enum.value)), nil
+}
+
+// UnmarshalText 在同类型枚举列表中查找, 需预设类型, eg: var method = mgr.Zero()
+func (enum *Enum) UnmarshalText(data []byte) error {
+	if enum.symbol == "" {
+		return fmt.Errorf("decode enum %q: symbol missing, initialize the field with EnumMgr.Zero()", data)
+	}
+	var mgr, ok = GetEnumMgr(enum.symbol)
+	if !ok {
+		return fmt.Errorf("enum symbol %q not registered", enum.symbol)
+	}
+	var e, err = mgr.Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*enum = e
+	return nil
+}
+
+// MarshalJSON 输出枚举值
+func (enum Enum) MarshalJSON() ([]byte, error) {
+	return json.Marshal(enum.value)
+}
+
+// UnmarshalJSON 支持字符串与数值, null 不修改
+func (enum *Enum) UnmarshalJSON(data []byte) error {
+	var text = strings.TrimSpace(string(data))
+	if text == "null" {
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	return enum.UnmarshalText([]byte(text))
+}
+
 func (enum *Enum) IsNull() bool {
 	if enum == nullEnum {
 		return true
@@ -100,6 +209,7 @@ func (enum *Enum) Export() *exportEnum {
 		Value:   enum.value,
 		Desc:    enum.desc,
 		Mapping: enum.mapping,
+		Aliases: enum.aliases,
 	}
 }
 
@@ -165,7 +275,7 @@ func (enums *enumLists) Index(e interface{}) (int, bool) {
 	for i, v := range *enums {
 		if enum != nil {
 			if enum.Equal(v) {
-				return i, false
+				return i, true
 			}
 			continue
 		}
@@ -182,6 +292,9 @@ func NewEnumMgr(symbol Symbol) *EnumMgr {
 	enumMgr.safe = sync.RWMutex{}
 	enumMgr.lists = new(enumLists)
 	enumMgr.once = sync.Once{}
+	enums.locker.Lock()
+	enums.mgrs[symbol] = enumMgr
+	enums.locker.Unlock()
 	return enumMgr
 }
 
@@ -207,7 +320,7 @@ func (mgr *EnumMgr) Index(v interface{}) (int, bool) {
 func (mgr *EnumMgr) In(enum *Enum) bool {
 	mgr.safe.Lock()
 	defer mgr.safe.Unlock()
-	if mgr.enable(enum) {
+	if !mgr.enable(enum) {
 		return false
 	}
 	if mgr.lists.In(enum) {
@@ -227,9 +340,64 @@ func (mgr *EnumMgr) enable(enum *Enum) bool {
 }
 
 func (mgr *EnumMgr) Get(v interface{}) (Enum, bool) {
+	mgr.safe.RLock()
+	defer mgr.safe.RUnlock()
 	return mgr.lists.Get(v)
 }
 
+// Lookup 按值, 描述或别名查找, 不区分大小写
+func (mgr *EnumMgr) Lookup(name string) (Enum, bool) {
+	mgr.safe.RLock()
+	defer mgr.safe.RUnlock()
+	for _, v := range *mgr.lists {
+		if v.Match(name) {
+			return *v, true
+		}
+	}
+	return *nullEnum, false
+}
+
+// Parse 查找枚举, 未匹配时返回列出全部可选值的错误
+func (mgr *EnumMgr) Parse(name string) (Enum, error) {
+	if enum, ok := mgr.Lookup(name); ok {
+		return enum, nil
+	}
+	return *nullEnum, fmt.Errorf("invalid %s %q, expect one of %s", mgr.Symbol(), name, strings.Join(mgr.Values(), "|"))
+}
+
+// Validate 校验名称是否为合法枚举
+func (mgr *EnumMgr) Validate(name string) error {
+	var _, err = mgr.Parse(name)
+	return err
+}
+
+// Values 按注册顺序列出枚举值
+func (mgr *EnumMgr) Values() []string {
+	mgr.safe.RLock()
+	defer mgr.safe.RUnlock()
+	var values = make([]string, 0, mgr.lists.Len())
+	for _, v := range *mgr.lists {
+		values = append(values, fmt.Sprintf("%v", v.value))
+	}
+	return values
+}
+
+// List 按注册顺序列出枚举
+func (mgr *EnumMgr) List() []Enum {
+	mgr.safe.RLock()
+	defer mgr.safe.RUnlock()
+	var list = make([]Enum, 0, mgr.lists.Len())
+	for _, v := range *mgr.lists {
+		list = append(list, *v)
+	}
+	return list
+}
+
+// Zero 未赋值的同类型枚举, 作为字段初始值时可由 UnmarshalText/UnmarshalJSON 解码
+func (mgr *EnumMgr) Zero() Enum {
+	return Enum{symbol: mgr.enumType}
+}
+
 func (mgr *EnumMgr) Symbol() Symbol {
 	mgr.safe.Lock()
 	defer mgr.safe.Unlock()
@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testColor Symbol = "color"
+
+func newTestColors() *EnumMgr {
+	var mgr = NewEnumMgr(testColor)
+	mgr.Add(NewEnum("red", testColor, "Red").SetCustom("#ff0000").SetAlias("r"))
+	mgr.Add(NewEnum("green", testColor, "Green").SetCustom("#00ff00"))
+	mgr.Add(NewEnum(3, testColor, "Blue"))
+	return mgr
+}
+
+func TestEnumMgr_Lookup(t *testing.T) {
+	var colors = newTestColors()
+	for _, name := range []string{"red", "RED", " Red ", "r"} {
+		if enum, ok := colors.Lookup(name); !ok || enum.Value() != "red" {
+			t.Errorf("Lookup(%q) = %v, %v", name, enum.Value(), ok)
+		}
+	}
+	if enum, ok := colors.Lookup("3"); !ok || enum.Desc() != "Blue" {
+		t.Errorf("numeric lookup failed %v", enum.Value())
+	}
+	if _, err := colors.Parse("purple"); err == nil || !strings.Contains(err.Error(), "red|green|3") {
+		t.Errorf("unexpected parse error %v", err)
+	}
+	if !reflect.DeepEqual(colors.Values(), []string{"red", "green", "3"}) || len(colors.List()) != 3 {
+		t.Errorf("unexpected values %v", colors.Values())
+	}
+	if i, ok := colors.Index("green"); i != 1 || !ok {
+		t.Errorf("Index by value = %d, %v", i, ok)
+	}
+	if i, ok := colors.Index(NewEnum("green", testColor)); i != 1 || !ok {
+		t.Errorf("Index by enum = %d, %v", i, ok)
+	}
+	if !colors.In(NewEnum("red", testColor)) || colors.In(NewEnum("red", LogLevel)) {
+		t.Error("In mismatch")
+	}
+}
+
+func TestEnum_Marshal(t *testing.T) {
+	var colors = newTestColors()
+	type palette struct {
+		Main  Enum `json:"main"`
+		Extra Enum `json:"extra"`
+	}
+	var (
+		p   = palette{Main: colors.Zero(), Extra: colors.Zero()}
+		err = json.Unmarshal([]byte(`{"main":"R","extra":3}`), &p)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Main.Value() != "red" || p.Extra.Desc() != "Blue" {
+		t.Errorf("unexpected palette %v %v", p.Main.Value(), p.Extra.Value())
+	}
+	var data, _ = json.Marshal(p)
+	if string(data) != `{"main":"red","extra":3}` {
+		t.Errorf("unexpected json %s", data)
+	}
+	if text, _ := p.Main.MarshalText(); string(text) != "red" {
+		t.Errorf("unexpected text %s", text)
+	}
+	var unknown = colors.Zero()
+	if err = unknown.UnmarshalText([]byte("purple")); err == nil {
+		t.Error("expect invalid color error")
+	}
+	// 零值 Enum 没有类型, 需以 Zero 初始化
+	var zero palette
+	if err = json.Unmarshal([]byte(`{"main":"red"}`), &zero); err == nil || !strings.Contains(err.Error(), "Zero()") {
+		t.Errorf("expect missing symbol error, got %v", err)
+	}
+}
@@ -23,12 +23,12 @@ func init() {
 
 // 注册消息
 func initLogLevels(mgr *EnumMgr) {
-	mgr.Add(NewEnum("panic", LogLevel, "FatalLevel").SetCustom(log.PanicLevel))
-	mgr.Add(NewEnum("fatal", LogLevel, "FatalLevel").SetCustom(log.FatalLevel))
-	mgr.Add(NewEnum("error", LogLevel, "ErrorLevel").SetCustom(log.ErrorLevel))
-	mgr.Add(NewEnum("warn", LogLevel, "WarnLevel").SetCustom(log.WarnLevel))
-	mgr.Add(NewEnum("info", LogLevel, "InfoLevel").SetCustom(log.InfoLevel))
-	mgr.Add(NewEnum("debug", LogLevel, "DebugLevel").SetCustom(log.DebugLevel))
+	mgr.Add(NewEnum("panic", LogLevel, "PanicLevel").SetCustom(log.PanicLevel).SetAlias("emerg", "emergency"))
+	mgr.Add(NewEnum("fatal", LogLevel, "FatalLevel").SetCustom(log.FatalLevel).SetAlias("crit", "critical", "alert"))
+	mgr.Add(NewEnum("error", LogLevel, "ErrorLevel").SetCustom(log.ErrorLevel).SetAlias("err"))
+	mgr.Add(NewEnum("warn", LogLevel, "WarnLevel").SetCustom(log.WarnLevel).SetAlias("warning"))
+	mgr.Add(NewEnum("info", LogLevel, "InfoLevel").SetCustom(log.InfoLevel).SetAlias("notice", "information"))
+	mgr.Add(NewEnum("debug", LogLevel, "DebugLevel").SetCustom(log.DebugLevel).SetAlias("dbg"))
 	mgr.Add(NewEnum("trace", LogLevel, "TraceLevel").SetCustom(log.TraceLevel))
 }
 
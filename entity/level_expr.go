@@ -0,0 +1,170 @@
+package entity
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 日志级别表达式, 逗号分隔多项并按顺序合并, 不区分大小写:
+//   warn, WARNING, err, 3      单个级别 (名称/别名/数值)
+//   >=warn, warn+, >warn       不低于/高于 warn 的级别 (panic 最高, trace 最低)
+//   <=info, <info              不高于/低于 info 的级别
+//   info..error                区间, 含两端
+//   all, *                     全部级别
+//   -debug, -info..trace       排除, 首项为排除时以全部级别为基础, eg: -debug => all,-debug
+
+// ParseLevel 解析单个级别, 支持名称, 别名 (warning, err, crit ...) 与数值 (0 panic ~ 6 trace)
+func ParseLevel(name string) (log.Level, error) {
+	var key = strings.TrimSpace(name)
+	if enum, ok := levelEnums.Lookup(key); ok {
+		return LogLevelOf(&enum), nil
+	}
+	if n, err := strconv.Atoi(key); err == nil {
+		if n < int(log.PanicLevel) || n > int(log.TraceLevel) {
+			return log.PanicLevel, fmt.Errorf("level %d out of range 0-%d", n, log.TraceLevel)
+		}
+		return log.Level(n), nil
+	}
+	return log.PanicLevel, fmt.Errorf("unknown level %q, expect panic|fatal|error|warn|info|debug|trace, alias or 0-%d", name, log.TraceLevel)
+}
+
+// ParseLevels 解析级别表达式, 多个参数按逗号拼接, 结果按严重程度从高到低排列
+func ParseLevels(exprs ...string) ([]log.Level, error) {
+	var (
+		expr     = strings.Join(exprs, ",")
+		selected = make(map[log.Level]bool)
+		terms    = 0
+	)
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term == "" {
+			continue
+		}
+		var exclude = strings.HasPrefix(term, "-")
+		if exclude {
+			term = strings.TrimSpace(term[1:])
+			if terms == 0 {
+				for _, level := range log.AllLevels {
+					selected[level] = true
+				}
+			}
+		}
+		var levels, err = parseLevelTerm(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level expression %q: %v", expr, err)
+		}
+		for _, level := range levels {
+			selected[level] = !exclude
+		}
+		terms++
+	}
+	if terms == 0 {
+		return nil, fmt.Errorf("empty level expression")
+	}
+	var result []log.Level
+	for level, ok := range selected {
+		if ok {
+			result = append(result, level)
+		}
+	}
+	if len(result) <= 0 {
+		return nil, fmt.Errorf("level expression %q matches no level", expr)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result, nil
+}
+
+// ParseLevelThreshold 解析最低级别, 表达式需为阈值形式 (warn, >=warn, warn+, <error ...), 不连续的集合返回错误
+func ParseLevelThreshold(expr string) (log.Level, error) {
+	var levels, err = ParseLevels(expr)
+	if err != nil {
+		return log.PanicLevel, err
+	}
+	var threshold = levels[len(levels)-1]
+	if len(levels) == 1 {
+		return threshold, nil
+	}
+	if levels[0] != log.PanicLevel || int(threshold) != len(levels)-1 {
+		return log.PanicLevel, fmt.Errorf("level expression %q is not a threshold, eg: warn, >=warn or warn+", expr)
+	}
+	return threshold, nil
+}
+
+func parseLevelTerm(term string) ([]log.Level, error) {
+	switch {
+	case term == "all" || term == "*":
+		return log.AllLevels, nil
+	case strings.HasPrefix(term, ">="):
+		var level, err = ParseLevel(term[2:])
+		if err != nil {
+			return nil, err
+		}
+		return levelsBetween(log.PanicLevel, level), nil
+	case strings.HasPrefix(term, "<="):
+		var level, err = ParseLevel(term[2:])
+		if err != nil {
+			return nil, err
+		}
+		return levelsBetween(level, log.TraceLevel), nil
+	case strings.HasPrefix(term, ">"):
+		var level, err = ParseLevel(term[1:])
+		if err != nil {
+			return nil, err
+		}
+		if level == log.PanicLevel {
+			return nil, fmt.Errorf("no level above panic")
+		}
+		return levelsBetween(log.PanicLevel, level-1), nil
+	case strings.HasPrefix(term, "<"):
+		var level, err = ParseLevel(term[1:])
+		if err != nil {
+			return nil, err
+		}
+		if level == log.TraceLevel {
+			return nil, fmt.Errorf("no level below trace")
+		}
+		return levelsBetween(level+1, log.TraceLevel), nil
+	case strings.HasSuffix(term, "+"):
+		var level, err = ParseLevel(strings.TrimSuffix(term, "+"))
+		if err != nil {
+			return nil, err
+		}
+		return levelsBetween(log.PanicLevel, level), nil
+	case strings.Contains(term, ".."):
+		var (
+			parts     = strings.SplitN(term, "..", 2)
+			from, err = ParseLevel(parts[0])
+		)
+		if err != nil {
+			return nil, err
+		}
+		to, err := ParseLevel(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return levelsBetween(from, to), nil
+	}
+	var level, err = ParseLevel(term)
+	if err != nil {
+		return nil, err
+	}
+	return []log.Level{level}, nil
+}
+
+// levelsBetween 两级别之间 (含两端) 的全部级别, 与参数顺序无关
+func levelsBetween(from, to log.Level) []log.Level {
+	if from > to {
+		from, to = to, from
+	}
+	var levels []log.Level
+	for level := from; level <= to; level++ {
+		levels = append(levels, level)
+	}
+	return levels
+}
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestParseLevel(t *testing.T) {
+	var cases = map[string]log.Level{
+		"warn":     log.WarnLevel,
+		"WARNING":  log.WarnLevel,
+		" Err ":    log.ErrorLevel,
+		"crit":     log.FatalLevel,
+		"emerg":    log.PanicLevel,
+		"5":        log.DebugLevel,
+		"0":        log.PanicLevel,
+		"TRACE":    log.TraceLevel,
+		"critical": log.FatalLevel,
+	}
+	for name, expect := range cases {
+		if level, err := ParseLevel(name); err != nil || level != expect {
+			t.Errorf("ParseLevel(%q) = %v, %v; expect %v", name, level, err, expect)
+		}
+	}
+	for _, name := range []string{"", "loud", "7", "-1"} {
+		if _, err := ParseLevel(name); err == nil {
+			t.Errorf("ParseLevel(%q) expect error", name)
+		}
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	var cases = []struct {
+		expr   []string
+		expect []log.Level
+	}{
+		{[]string{"warn", "error"}, []log.Level{log.ErrorLevel, log.WarnLevel}},
+		{[]string{">=warn"}, []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel}},
+		{[]string{"warning+"}, []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel}},
+		{[]string{">error"}, []log.Level{log.PanicLevel, log.FatalLevel}},
+		{[]string{"<=debug"}, []log.Level{log.DebugLevel, log.TraceLevel}},
+		{[]string{"error..info"}, []log.Level{log.ErrorLevel, log.WarnLevel, log.InfoLevel}},
+		{[]string{"all,-debug,-trace"}, []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel, log.InfoLevel}},
+		{[]string{"-info..trace"}, []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel}},
+		{[]string{"*", "-3"}, []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.InfoLevel, log.DebugLevel, log.TraceLevel}},
+	}
+	for _, c := range cases {
+		var levels, err = ParseLevels(c.expr...)
+		if err != nil || !reflect.DeepEqual(levels, c.expect) {
+			t.Errorf("ParseLevels(%q) = %v, %v; expect %v", c.expr, levels, err, c.expect)
+		}
+	}
+	for _, expr := range []string{"", "warn,loud", ">panic", "<trace", "info..", "all,-all", ">=8"} {
+		if _, err := ParseLevels(expr); err == nil {
+			t.Errorf("ParseLevels(%q) expect error", expr)
+		}
+	}
+}
+
+func TestParseLevelThreshold(t *testing.T) {
+	for expr, expect := range map[string]log.Level{"warn": log.WarnLevel, ">=info": log.InfoLevel, "err+": log.ErrorLevel, "panic..error": log.ErrorLevel} {
+		if level, err := ParseLevelThreshold(expr); err != nil || level != expect {
+			t.Errorf("ParseLevelThreshold(%q) = %v, %v; expect %v", expr, level, err, expect)
+		}
+	}
+	for _, expr := range []string{"info..error", "all,-debug", "loud"} {
+		if _, err := ParseLevelThreshold(expr); err == nil {
+			t.Errorf("ParseLevelThreshold(%q) expect error", expr)
+		}
+	}
+}
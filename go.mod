@@ -3,14 +3,10 @@ module github.com/weblfe/logrus_hooks
 go 1.17
 
 require (
-	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible
+	github.com/lestrrat-go/strftime v1.0.5
 	github.com/rifflock/lfshook v0.0.0-20180920164130-b9218ef580f5
 	github.com/sirupsen/logrus v1.8.1
+	golang.org/x/sys v0.0.0-20191026070338-33540a1f6037
 )
 
-require (
-	github.com/jonboulle/clockwork v0.2.2 // indirect
-	github.com/lestrrat-go/strftime v1.0.5 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
-	golang.org/x/sys v0.0.0-20191026070338-33540a1f6037 // indirect
-)
+require github.com/pkg/errors v0.9.1 // indirect
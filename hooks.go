@@ -39,6 +39,7 @@ func GetMgr() facede.HookMgr {
 func init() {
 	// 被动注册
 	Add(rotate.CreateRotateFactory())
+	Add(rotate.CreateAuditFactory())
 	// 主动注册
 	notify.Register(GetMgr())
 }
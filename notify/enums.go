@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"net/http"
+
+	"github.com/weblfe/logrus_hooks/entity"
+)
+
+type (
+	// Method 请求方法, 按方法枚举解码 (值, 描述或别名, 不区分大小写), eg: post, POST
+	Method string
+
+	// ContentType 请求内容格式, 按内容格式枚举解码, eg: json, application/json
+	ContentType string
+)
+
+const (
+	SymbolMethod      entity.Symbol = "method"
+	SymbolContentType entity.Symbol = "content_type"
+)
+
+var (
+	methodEnums      = newMethodEnums()
+	contentTypeEnums = newContentTypeEnums()
+)
+
+// 请求方法, 映射值为 http 方法名
+func newMethodEnums() *entity.EnumMgr {
+	var mgr = entity.NewEnumMgr(SymbolMethod)
+	mgr.Add(entity.NewEnum("get", SymbolMethod, "HTTP GET").SetCustom(http.MethodGet))
+	mgr.Add(entity.NewEnum("post", SymbolMethod, "HTTP POST").SetCustom(http.MethodPost))
+	mgr.Add(entity.NewEnum("put", SymbolMethod, "HTTP PUT").SetCustom(http.MethodPut))
+	mgr.Add(entity.NewEnum("delete", SymbolMethod, "HTTP DELETE").SetCustom(http.MethodDelete).SetAlias("del"))
+	return mgr
+}
+
+// 请求内容格式, 描述为对应的 Content-Type
+func newContentTypeEnums() *entity.EnumMgr {
+	var mgr = entity.NewEnumMgr(SymbolContentType)
+	mgr.Add(entity.NewEnum(ContentTypeJson, SymbolContentType, "application/json"))
+	mgr.Add(entity.NewEnum(ContentTypeFrom, SymbolContentType, "application/x-www-form-urlencoded").SetAlias("urlencoded"))
+	mgr.Add(entity.NewEnum(ContentTypeQuery, SymbolContentType, "url query"))
+	mgr.Add(entity.NewEnum(ContentTypeText, SymbolContentType, "text/plain").SetAlias("plain"))
+	mgr.Add(entity.NewEnum(ContentTypeXml, SymbolContentType, "application/xml").SetAlias("text/xml"))
+	mgr.Add(entity.NewEnum(ContentTypePath, SymbolContentType, "url path"))
+	return mgr
+}
+
+// GetMethods 请求方法枚举
+func GetMethods() *entity.EnumMgr {
+	return methodEnums
+}
+
+// GetContentTypes 请求内容格式枚举
+func GetContentTypes() *entity.EnumMgr {
+	return contentTypeEnums
+}
+
+// methodNames http 方法名, 按注册顺序
+func methodNames() []string {
+	var names []string
+	for _, v := range methodEnums.List() {
+		names = append(names, v.GetCustom().(string))
+	}
+	return names
+}
+
+// UnmarshalText 解码为枚举值, 空文本为未设置
+func (method *Method) UnmarshalText(data []byte) error {
+	var value, err = parseEnumText(methodEnums, data)
+	if err != nil {
+		return err
+	}
+	*method = Method(value)
+	return nil
+}
+
+func (method Method) String() string {
+	return string(method)
+}
+
+// UnmarshalText 解码为枚举值, 空文本为未设置
+func (contentType *ContentType) UnmarshalText(data []byte) error {
+	var value, err = parseEnumText(contentTypeEnums, data)
+	if err != nil {
+		return err
+	}
+	*contentType = ContentType(value)
+	return nil
+}
+
+func (contentType ContentType) String() string {
+	return string(contentType)
+}
+
+func parseEnumText(mgr *entity.EnumMgr, data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+	var enum, err = mgr.Parse(string(data))
+	if err != nil {
+		return "", err
+	}
+	return enum.Value().(string), nil
+}
@@ -26,17 +26,25 @@ func (n *notifyFactoryImpl) Create(args ...interface{}) (log.Hook, error) {
 		args = append(args, n.options)
 	}
 	if n.options == args[0] {
-		n.hook = NewHttpWebHook(*n.options)
+		var hook, err = NewHttpWebHookE(*n.options)
+		if err != nil {
+			return nil, err
+		}
+		n.hook = hook
 		return n.hook, nil
 	}
-	var (
-		info    = args[0]
-		options = NewOptions(info)
-	)
+	var options, err = NewOptionsE(args[0])
+	if err != nil {
+		return nil, err
+	}
 	if options == nil {
 		return nil, errors.New("options missing call notifyFactoryImpl.Create")
 	}
-	return NewHttpWebHook(*options), nil
+	hook, err := NewHttpWebHookE(*options)
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
 }
 
 func CreateNotifyFactory(options *Options) *notifyFactoryImpl {
@@ -18,6 +18,7 @@ type httpHookImpl struct {
 	client      facede.WebHookClient
 }
 
+// NewHttpWebHook 构建 webhook, 不校验参数, 非法的请求方法与内容格式按默认值处理, 校验见 NewHttpWebHookE
 func NewHttpWebHook(options Options) *httpHookImpl {
 	var hook = new(httpHookImpl)
 	hook.hookName = options.Name
@@ -26,6 +27,14 @@ func NewHttpWebHook(options Options) *httpHookImpl {
 	return hook
 }
 
+// NewHttpWebHookE 构建 webhook, 请求方法, 内容格式或级别表达式非法时返回错误
+func NewHttpWebHookE(options Options) (*httpHookImpl, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+	return NewHttpWebHook(options), nil
+}
+
 func (hook *httpHookImpl) SetClient(client facede.WebHookClient) bool {
 	if hook == nil || hook.client != nil {
 		return false
@@ -55,7 +64,7 @@ func (hook *httpHookImpl) Fire(entry *log.Entry) error {
 	if client == nil {
 		return nil
 	}
-	return client.Send(hook.parseData(entry.Message,entry.Data,entry.Time))
+	return client.Send(hook.parseData(entry.Message, entry.Data, entry.Time))
 }
 
 func (hook *httpHookImpl) checkLevel(level log.Level) bool {
@@ -73,15 +82,15 @@ func (hook *httpHookImpl) checkLevel(level log.Level) bool {
 func (hook *httpHookImpl) GetOptions() Options {
 	var opt = Options{
 		Url:         hook.hookUrl,
-		Method:      hook.method,
-		ContentType: hook.contentType,
+		Method:      Method(hook.method),
+		ContentType: ContentType(hook.contentType),
 		Name:        hook.hookName,
 		Levels:      entity.Levels(hook.levels).StringerArray(),
 	}
 	return opt
 }
 
-func (hook *httpHookImpl) parseData(msg string,data log.Fields,at time.Time) map[string]string {
+func (hook *httpHookImpl) parseData(msg string, data log.Fields, at time.Time) map[string]string {
 	var kv = make(map[string]string)
 	for k, v := range data {
 		kv[k] = utils.NewStringer(v).String()
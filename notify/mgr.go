@@ -2,6 +2,7 @@ package notify
 
 import (
 	"errors"
+	log "github.com/sirupsen/logrus"
 	"github.com/weblfe/logrus_hooks/facede"
 )
 
@@ -40,7 +41,13 @@ func (mgr *notifyMgrImpl) findByEnv(faceID string) facede.Creator {
 	if faceID == "" {
 		return nil
 	}
-	var options = NewOptionWithEnvPrefix(faceID)
+	var options, err = NewOptionWithEnvPrefixE(faceID)
+	// 环境变量解析失败, 构造时返回错误
+	if err != nil {
+		return func(...interface{}) (log.Hook, error) {
+			return nil, err
+		}
+	}
 	if options == nil || options.Url == "" {
 		return nil
 	}
@@ -13,11 +13,11 @@ import (
 )
 
 type Options struct {
-	Url         string   `json:"url" yaml:"url" env:"url"`
-	Name        string   `json:"name" yaml:"name" env:"name"`
-	Levels      []string `json:"level" yaml:"level" env:"level"`
-	Method      string   `json:"method" yaml:"method" env:"method,post"`
-	ContentType string   `json:"content_type" yaml:"content_type" env:"content_type,json"`
+	Url         string      `json:"url" yaml:"url" env:"url" secret:"true" desc:"webhook 地址"`
+	Name        string      `json:"name" yaml:"name" env:"name" desc:"hook 名称, 默认为前缀"`
+	Levels      []string    `json:"level" yaml:"level" env:"level" desc:"通知的日志级别, 逗号分隔, 支持 >=warn, info..error, all,-debug, 为空时全部级别"`
+	Method      Method      `json:"method" yaml:"method" env:"method,post" desc:"请求方法, get|post|put|delete, 不区分大小写"`
+	ContentType ContentType `json:"content_type" yaml:"content_type" env:"content_type,json" desc:"请求内容格式, json|form|query|text|xml|path 或 Content-Type, eg: application/json"`
 	logLevels   []log.Level
 }
 
@@ -34,23 +34,18 @@ const (
 )
 
 var (
-	AllSupportMethods = []string{
-		http.MethodGet,
-		http.MethodPost,
-		http.MethodPut,
-		http.MethodDelete,
-	}
-	AllSupportContentTypes = []string{
-		ContentTypeJson,
-		ContentTypeFrom,
-		ContentTypeQuery,
-		ContentTypeText,
-		ContentTypeXml,
-		ContentTypePath,
-	}
+	AllSupportMethods      = methodNames()
+	AllSupportContentTypes = contentTypeEnums.Values()
 )
 
+// NewOptionWithEnvPrefix 从前缀环境变量构建 Options, 忽略解析/校验错误, 错误见 NewOptionWithEnvPrefixE
 func NewOptionWithEnvPrefix(prefix string, caseMode ...utils.CaseMode) *Options {
+	var opt, _ = NewOptionWithEnvPrefixE(prefix, caseMode...)
+	return opt
+}
+
+// NewOptionWithEnvPrefixE 从前缀环境变量构建 Options, 解析/校验失败的字段返回 utils.EnvErrors
+func NewOptionWithEnvPrefixE(prefix string, caseMode ...utils.CaseMode) (*Options, error) {
 	var (
 		opt = new(Options)
 	)
@@ -59,16 +54,21 @@ func NewOptionWithEnvPrefix(prefix string, caseMode ...utils.CaseMode) *Options
 	if prefix != "" {
 		decoder.SetPrefix(prefix)
 	}
-	if err := decoder.Marshal(opt); err != nil {
-		return nil
-	}
+	var err = decoder.Marshal(opt)
 	if opt.Name == "" {
 		opt.Name = strings.TrimPrefix(prefix, "_")
 	}
-	return opt
+	return opt, err
 }
 
+// NewOptionWithEnv 从环境变量构建 Options, 忽略解析/校验错误, 错误见 NewOptionWithEnvE
 func NewOptionWithEnv(caseMode utils.CaseMode, prefix ...string) *Options {
+	var opt, _ = NewOptionWithEnvE(caseMode, prefix...)
+	return opt
+}
+
+// NewOptionWithEnvE 从环境变量构建 Options, 解析/校验失败的字段返回 utils.EnvErrors
+func NewOptionWithEnvE(caseMode utils.CaseMode, prefix ...string) (*Options, error) {
 	var (
 		argc    = len(prefix)
 		opt     = new(Options)
@@ -84,18 +84,23 @@ func NewOptionWithEnv(caseMode utils.CaseMode, prefix ...string) *Options {
 	if argc >= 2 && prefix[1] != "" {
 		decoder.SetPrefix(prefix[1])
 	}
-	if err := decoder.Marshal(opt); err != nil {
-		return nil
-	}
+	var err = decoder.Marshal(opt)
 	if opt.Name == "" && prefix[0] != "" {
 		opt.Name = strings.TrimPrefix(prefix[0], "_")
 	}
-	return opt
+	return opt, err
 }
 
+// NewOptions 按参数构建 Options, 忽略解析/校验错误, 错误见 NewOptionsE
 func NewOptions(arg interface{}) *Options {
+	var opt, _ = NewOptionsE(arg)
+	return opt
+}
+
+// NewOptionsE 按参数构建 Options, 从环境变量读取时返回解析/校验错误 (utils.EnvErrors)
+func NewOptionsE(arg interface{}) (*Options, error) {
 	if arg == nil {
-		return NewOptionWithEnvPrefix("notify")
+		return NewOptionWithEnvPrefixE("notify")
 	}
 	var through = false
 	for {
@@ -107,7 +112,7 @@ func NewOptions(arg interface{}) *Options {
 			)
 			if json.Valid(bytes) {
 				if err := json.Unmarshal(bytes, options); err == nil {
-					return options
+					return options, nil
 				}
 			}
 			arg = string(bytes)
@@ -116,13 +121,13 @@ func NewOptions(arg interface{}) *Options {
 			through = false
 			var key = arg.(string)
 			if key == "" {
-				return NewOptionWithEnvPrefix("notify", utils.UpperCase)
+				return NewOptionWithEnvPrefixE("notify", utils.UpperCase)
 			}
 			// eg: case=1&prefix=app_&suffix=_logger
 			if strings.Contains(key, "=") {
 				var values, err = url.ParseQuery(key)
 				if err != nil {
-					return NewOptionWithEnvPrefix("notify", utils.UpperCase)
+					return NewOptionWithEnvPrefixE("notify", utils.UpperCase)
 				}
 				var (
 					prefix, suffix string
@@ -165,61 +170,55 @@ func NewOptions(arg interface{}) *Options {
 				if suffix != "" {
 					args[1] = suffix
 				}
-				return NewOptionWithEnv(caseMode, args...)
+				return NewOptionWithEnvE(caseMode, args...)
 			}
 			// eg: app_,_logger
 			if strings.Contains(key, ",") {
 				var kArr = strings.Split(key, ",")
-				return NewOptionWithEnv(utils.UpperCase, kArr...)
+				return NewOptionWithEnvE(utils.UpperCase, kArr...)
 			}
 			// namespace prefix
 			if strings.Contains(key, "_") {
 				key = strings.TrimPrefix(key, "_")
 			}
-			NewOptionWithEnv(utils.UpperCase, key)
+			return NewOptionWithEnvE(utils.UpperCase, key)
 		case *Options:
-			return arg.(*Options)
+			return arg.(*Options), nil
 		case Options:
 			var opt = arg.(Options)
-			return &opt
+			return &opt, nil
 		}
 		if !through {
 			break
 		}
 	}
 
-	return nil
+	return nil, fmt.Errorf("unsupported notify options %T", arg)
 }
 
+// GetLevels 通知的日志级别, 为空时全部级别; 表达式非法时返回 nil, 错误信息见 ParseLevels
 func (options *Options) GetLevels() []log.Level {
+	var levels, _ = options.ParseLevels()
+	return levels
+}
+
+// ParseLevels 解析级别表达式, eg: warn,error | >=warn | warn+ | info..error | all,-debug, 结果缓存
+func (options *Options) ParseLevels() ([]log.Level, error) {
 	// 已解析过
 	if len(options.logLevels) > 0 {
-		return options.logLevels
+		return options.logLevels, nil
 	}
 	// 无限定日志level
 	if len(options.Levels) <= 0 {
 		options.logLevels = log.AllLevels
-		return options.logLevels
-	}
-	var (
-		enums = entity.GetLevels()
-		cache = make(map[log.Level]int)
-	)
-	for i, v := range options.Levels {
-		if enum, ok := enums.Get(v); ok {
-			var level = entity.LogLevelOf(&enum)
-			if _, ok := cache[level]; ok {
-				continue
-			}
-			cache[level] = i
-			options.logLevels = append(options.logLevels, level)
-		}
+		return options.logLevels, nil
 	}
-	// 指定参数异常 默认限定 warn level
-	if len(options.logLevels) <= 0 {
-		options.logLevels = append(options.logLevels, log.WarnLevel)
+	var levels, err = entity.ParseLevels(options.Levels...)
+	if err != nil {
+		return nil, err
 	}
-	return options.logLevels
+	options.logLevels = levels
+	return options.logLevels, nil
 }
 
 func (options *Options) GetUrl() string {
@@ -240,28 +239,40 @@ func (options *Options) GetURI() *url.URL {
 	return nil
 }
 
+// GetMethod 请求方法 (http 方法名), 未设置或非法时为 POST, 校验见 Check
 func (options *Options) GetMethod() string {
 	if options == nil || options.Method == "" {
 		return defaultHttpMethod
 	}
-	var method = strings.ToLower(options.Method)
-	for _, v := range AllSupportMethods {
-		if v == method {
-			return v
-		}
+	if enum, ok := methodEnums.Lookup(string(options.Method)); ok {
+		return enum.GetCustom().(string)
 	}
 	return defaultHttpMethod
 }
 
+// GetContentType 请求内容格式, 支持 Content-Type 写法, 未设置或非法时为 json, 校验见 Check
 func (options *Options) GetContentType() string {
 	if options == nil || options.ContentType == "" {
 		return defaultHttpContentType
 	}
-	var contentType = strings.ToLower(options.ContentType)
-	for _, v := range AllSupportContentTypes {
-		if v == contentType {
-			return v
-		}
+	if enum, ok := contentTypeEnums.Lookup(string(options.ContentType)); ok {
+		return enum.Value().(string)
 	}
 	return defaultHttpContentType
 }
+
+// Check 校验请求方法, 内容格式与级别表达式
+func (options *Options) Check() error {
+	if options.Method != "" {
+		if err := methodEnums.Validate(string(options.Method)); err != nil {
+			return err
+		}
+	}
+	if options.ContentType != "" {
+		if err := contentTypeEnums.Validate(string(options.ContentType)); err != nil {
+			return err
+		}
+	}
+	var _, err = options.ParseLevels()
+	return err
+}
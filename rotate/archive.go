@@ -0,0 +1,153 @@
+package rotate
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Archiver 已关闭 (及压缩后) 的日志分段归档接口
+	Archiver interface {
+		Archive(path string) error
+	}
+
+	// ArchiverFunc 函数转换为归档器
+	ArchiverFunc func(path string) error
+
+	// localArchiver 本地目录归档
+	localArchiver struct {
+		dir string
+	}
+)
+
+const (
+	RemoveByArchived = "archived"
+)
+
+func (fn ArchiverFunc) Archive(path string) error {
+	return fn(path)
+}
+
+// NewLocalArchiver 归档到本地目录
+func NewLocalArchiver(dir string) Archiver {
+	var archiver = new(localArchiver)
+	archiver.dir = dir
+	return archiver
+}
+
+// NewArchiver 按 url 构建归档器
+// eg: /data/archive, file:///data/archive,
+// s3://bucket/prefix?endpoint=http://127.0.0.1:9000&region=us-east-1&path_style=true
+// s3 凭证读取 AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN 环境变量
+func NewArchiver(rawUrl string) (Archiver, error) {
+	rawUrl = strings.TrimSpace(rawUrl)
+	if rawUrl == "" {
+		return nil, nil
+	}
+	if !strings.Contains(rawUrl, "://") {
+		return NewLocalArchiver(rawUrl), nil
+	}
+	var uri, err = url.Parse(rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive url %q: %v", rawUrl, err)
+	}
+	switch strings.ToLower(uri.Scheme) {
+	case "file":
+		return NewLocalArchiver(filepath.FromSlash(uri.Host + uri.Path)), nil
+	case "s3":
+		var (
+			query  = uri.Query()
+			config = S3Config{
+				Endpoint:     query.Get("endpoint"),
+				Region:       query.Get("region"),
+				Bucket:       uri.Host,
+				Prefix:       strings.TrimPrefix(uri.Path, "/"),
+				AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			}
+		)
+		if v := query.Get("path_style"); v != "" {
+			config.PathStyle, _ = strconv.ParseBool(v)
+		}
+		return NewS3Archiver(config)
+	}
+	return nil, fmt.Errorf("unsupported archive url %q", rawUrl)
+}
+
+// Archive 复制分段到归档目录
+func (archiver *localArchiver) Archive(path string) error {
+	if err := os.MkdirAll(archiver.dir, 0755); err != nil {
+		return err
+	}
+	var src, err = os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	var (
+		target  = filepath.Join(archiver.dir, filepath.Base(path))
+		tmpName = target + ".tmp"
+	)
+	dst, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, target)
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	_ = os.Chtimes(target, info.ModTime(), info.ModTime())
+	return nil
+}
+
+// archive 归档分段, 失败时加入待重试列表, 下次分段切换时重试
+func (writer *rotateWriter) archive(name string) {
+	if writer.archiver == nil {
+		return
+	}
+	if err := writer.archiver.Archive(name); err != nil {
+		writer.report(fmt.Errorf("archive %s failed: %v", name, err))
+		writer.pending[name] = true
+		return
+	}
+	delete(writer.pending, name)
+	writer.manifestUpdate(name, func(manifest *Manifest, meta *SegmentMeta) {
+		meta.Archived = true
+	})
+	if !writer.archiveRemove {
+		return
+	}
+	if info, err := os.Stat(name); err == nil {
+		writer.remove(segmentInfo{path: name, modTime: info.ModTime(), size: info.Size()}, RemoveByArchived)
+	}
+}
+
+// retryArchive 重试上次归档失败的分段
+func (writer *rotateWriter) retryArchive() {
+	for name := range writer.pending {
+		if _, err := os.Stat(name); err != nil {
+			delete(writer.pending, name)
+			continue
+		}
+		writer.archive(name)
+	}
+}
@@ -0,0 +1,212 @@
+package rotate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// S3Config S3 兼容存储归档参数
+	S3Config struct {
+		Endpoint     string
+		Region       string
+		Bucket       string
+		Prefix       string
+		AccessKey    string
+		SecretKey    string
+		SessionToken string
+		// PathStyle 使用 endpoint/bucket/key 路径形式 (minio 等本地存储)
+		PathStyle bool
+		Client    *http.Client
+	}
+
+	// s3Archiver 通过 REST API (SigV4 签名) 上传分段
+	s3Archiver struct {
+		config   S3Config
+		endpoint *url.URL
+		client   *http.Client
+		clock    Clock
+	}
+)
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat  = "20060102T150405Z"
+	sigV4DateFormat  = "20060102"
+	s3Service        = "s3"
+	defaultS3Region  = "us-east-1"
+	amzContentSha256 = "X-Amz-Content-Sha256"
+	amzDate          = "X-Amz-Date"
+	amzSecurityToken = "X-Amz-Security-Token"
+)
+
+// NewS3Archiver 构建 S3 兼容归档器
+func NewS3Archiver(config S3Config) (Archiver, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("s3 archiver bucket missing")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("s3 archiver credentials missing")
+	}
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	var endpoint, err = url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", config.Endpoint)
+	}
+	var archiver = new(s3Archiver)
+	archiver.config = config
+	archiver.endpoint = endpoint
+	archiver.client = config.Client
+	if archiver.client == nil {
+		archiver.client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	archiver.clock = UTC
+	return archiver, nil
+}
+
+// Archive PUT Object 上传分段
+func (archiver *s3Archiver) Archive(name string) error {
+	var file, err = os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var hash = sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, archiver.objectUrl(filepath.Base(name)), file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	signV4(req, hex.EncodeToString(hash.Sum(nil)), archiver.config, archiver.clock.Now())
+	res, err := archiver.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		var body, _ = ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("s3 put %s response %s: %s", req.URL.Path, res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (archiver *s3Archiver) objectUrl(name string) string {
+	var (
+		uri = *archiver.endpoint
+		key = strings.Trim(path.Join(archiver.config.Prefix, name), "/")
+	)
+	if archiver.config.PathStyle {
+		uri.Path = path.Join("/", uri.Path, archiver.config.Bucket, key)
+	} else {
+		uri.Host = archiver.config.Bucket + "." + uri.Host
+		uri.Path = path.Join("/", uri.Path, key)
+	}
+	return uri.String()
+}
+
+// signV4 AWS Signature Version 4 请求签名 (签名 host 及全部 x-amz-* 请求头)
+func signV4(req *http.Request, payloadHash string, config S3Config, now time.Time) {
+	var (
+		amzTime = now.UTC().Format(sigV4TimeFormat)
+		date    = now.UTC().Format(sigV4DateFormat)
+		scope   = strings.Join([]string{date, config.Region, s3Service, "aws4_request"}, "/")
+	)
+	req.Header.Set(amzDate, amzTime)
+	req.Header.Set(amzContentSha256, payloadHash)
+	if config.SessionToken != "" {
+		req.Header.Set(amzSecurityToken, config.SessionToken)
+	}
+	var (
+		headers          = map[string]string{"host": req.URL.Host}
+		signedHeaders    []string
+		canonicalHeaders strings.Builder
+	)
+	for k, v := range req.Header {
+		var key = strings.ToLower(k)
+		if strings.HasPrefix(key, "x-amz-") || key == "range" || key == "content-md5" {
+			headers[key] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	for k := range headers {
+		signedHeaders = append(signedHeaders, k)
+	}
+	sort.Strings(signedHeaders)
+	for _, k := range signedHeaders {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	var (
+		canonicalRequest = strings.Join([]string{
+			req.Method,
+			req.URL.EscapedPath(),
+			canonicalQuery(req.URL.Query()),
+			canonicalHeaders.String(),
+			strings.Join(signedHeaders, ";"),
+			payloadHash,
+		}, "\n")
+		requestHash  = sha256.Sum256([]byte(canonicalRequest))
+		stringToSign = strings.Join([]string{sigV4Algorithm, amzTime, scope, hex.EncodeToString(requestHash[:])}, "\n")
+		signingKey   = hmacSha256([]byte("AWS4"+config.SecretKey), date)
+	)
+	for _, v := range []string{config.Region, s3Service, "aws4_request"} {
+		signingKey = hmacSha256(signingKey, v)
+	}
+	var signature = hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, config.AccessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+func canonicalQuery(values url.Values) string {
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		var vs = values[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, sigV4Escape(k)+"="+sigV4Escape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	var mac = hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
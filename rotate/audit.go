@@ -0,0 +1,376 @@
+package rotate
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/weblfe/logrus_hooks/utils"
+)
+
+// 审计日志每行以 chain=<sha256(上一行哈希 + "\n" + 本行内容)> 结尾, 每次切换分段时写入检查点行,
+// eg: audit=checkpoint time=... segment=app-20210102.log prev_segment=app-20210101.log prev_chain=... chain=...
+const (
+	AuditHookName  = "audit"
+	AuditEnvPrefix = "audit"
+
+	auditChainKey   = " chain="
+	auditCheckpoint = "audit=checkpoint"
+)
+
+type (
+	// 审计 hook 工厂
+	auditHookFactory struct {
+		defaultOption *Options
+		name          string
+	}
+
+	// auditHook 哈希链审计 hook, 链状态只在写入器锁内读写
+	auditHook struct {
+		writer    *rotateWriter
+		formatter log.Formatter
+		prev      string
+		segment   string
+		resumed   bool
+	}
+
+	// AuditReport 审计日志校验结果, Anchor 为首个分段之前的链哈希, LastChain 可交由外部留存防止尾部截断
+	AuditReport struct {
+		Segments  []string `json:"segments"`
+		Lines     int64    `json:"lines"`
+		Anchor    string   `json:"anchor"`
+		LastChain string   `json:"last_chain"`
+	}
+
+	// auditSegment 待校验分段
+	auditSegment struct {
+		path        string
+		name        string
+		prevSegment string
+	}
+)
+
+var (
+	auditGenesis = strings.Repeat("0", sha256.Size*2)
+)
+
+func CreateAuditFactory() *auditHookFactory {
+	var factory = new(auditHookFactory)
+	factory.name = AuditHookName
+	return factory
+}
+
+// Create 构建审计 hook, 默认参数读取 AUDIT_ 前缀环境变量
+func (factory *auditHookFactory) Create(args ...interface{}) (log.Hook, error) {
+	var (
+		options *Options
+		err     error
+	)
+	if len(args) == 0 {
+		options, err = factory.getDefaultOption()
+	} else {
+		options, err = NewOptionE(args[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewAuditHook(options)
+}
+
+func (factory *auditHookFactory) getDefaultOption() (*Options, error) {
+	if factory.defaultOption == nil {
+		var options, err = CreateOptionsWithEnvE(utils.UpperCase, AuditEnvPrefix)
+		if err != nil {
+			return nil, err
+		}
+		factory.defaultOption = options
+	}
+	return factory.defaultOption, nil
+}
+
+func (factory *auditHookFactory) Face() string {
+	return factory.name
+}
+
+func (factory *auditHookFactory) SetDefaultOption(options *Options) *auditHookFactory {
+	if factory.defaultOption == nil && options != nil {
+		factory.defaultOption = options
+	}
+	return factory
+}
+
+// NewAuditHook 构建审计 hook, 记录全部级别, 不支持 reopen 与多进程共享模式
+func NewAuditHook(options *Options) (*auditHook, error) {
+	if options == nil {
+		return nil, errors.New("audit hook options missing")
+	}
+	if options.GetMode() != ModeRotate || options.Shared {
+		return nil, errors.New("audit hook requires rotate mode without shared writers")
+	}
+	var writer, err = newRotateWriter(options)
+	if err != nil {
+		return nil, err
+	}
+	var hook = new(auditHook)
+	hook.writer = writer
+	hook.formatter = &log.TextFormatter{DisableColors: true}
+	hook.prev = auditGenesis
+	writer.onOpen = hook.checkpoint
+	return hook, nil
+}
+
+func (hook *auditHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (hook *auditHook) Fire(entry *log.Entry) error {
+	var body, err = hook.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	var line = strings.TrimRight(string(body), "\n")
+	_, err = hook.writer.writeWith(int64(len(line)+len(auditChainKey)+len(auditGenesis)+1), func() []byte {
+		return hook.chain(line)
+	})
+	return err
+}
+
+// Rotate 强制切换分段并写入检查点
+func (hook *auditHook) Rotate() error {
+	return hook.writer.Rotate()
+}
+
+func (hook *auditHook) Close() error {
+	return hook.writer.Close()
+}
+
+// chain 计算本行哈希并推进链
+func (hook *auditHook) chain(line string) []byte {
+	hook.prev = auditHash(hook.prev, line)
+	return []byte(line + auditChainKey + hook.prev + "\n")
+}
+
+// checkpoint 分段打开时写入检查点, 首次打开时从已有分段恢复链状态
+func (hook *auditHook) checkpoint(previous, name string) {
+	var writer = hook.writer
+	if !hook.resumed {
+		hook.resumed = true
+		hook.resume(name)
+	}
+	var line = fmt.Sprintf("%s time=%s segment=%s prev_segment=%s prev_chain=%s", auditCheckpoint,
+		writer.clock.Now().Format(time.RFC3339), filepath.Base(name), hook.segment, hook.prev)
+	if _, err := writer.write(hook.chain(line)); err != nil {
+		writer.report(err)
+	}
+	hook.segment = filepath.Base(name)
+}
+
+// resume 读取当前分段或最近分段的最后一个链哈希
+func (hook *auditHook) resume(name string) {
+	var candidates = []string{name}
+	if segments := hook.writer.match(hook.writer.globPattern, name); len(segments) > 0 {
+		candidates = append(candidates, segments[len(segments)-1].path)
+	}
+	for _, path := range candidates {
+		var chain, err = lastAuditChain(path, hook.writer.keys)
+		if err != nil {
+			hook.writer.report(err)
+			continue
+		}
+		if chain != "" {
+			hook.prev = chain
+			hook.segment = segmentBaseName(path)
+			return
+		}
+	}
+}
+
+func lastAuditChain(name string, keys *KeyRing) (string, error) {
+	var last string
+	var err = readAuditLines(name, keys, func(lineNo int, line string) error {
+		if _, chain, ok := splitAuditLine(line); ok {
+			last = chain
+		}
+		return nil
+	})
+	return last, err
+}
+
+// VerifyAudit 按配置查找审计分段 (含 .gz 与加密分段) 并校验哈希链
+func VerifyAudit(options *Options) (*AuditReport, error) {
+	var pattern, err = options.GetGlobPattern()
+	if err != nil {
+		return nil, err
+	}
+	_, keys, err := options.getEncryption()
+	if err != nil {
+		return nil, err
+	}
+	names, err := globSegments(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyAuditFiles(keys, names...)
+}
+
+// VerifyAuditFiles 校验审计分段, 按检查点还原分段顺序, 可发现被修改, 调序, 删除的行以及缺失的分段
+func VerifyAuditFiles(keys *KeyRing, names ...string) (*AuditReport, error) {
+	var (
+		segments = make(map[string]*auditSegment)
+		next     = make(map[string]*auditSegment)
+	)
+	if len(names) == 0 {
+		return nil, errors.New("no audit segment found")
+	}
+	for _, path := range names {
+		var segment, err = readAuditSegment(path, keys)
+		if err != nil {
+			return nil, err
+		}
+		if exists, ok := segments[segment.name]; ok {
+			return nil, fmt.Errorf("duplicated audit segment %s and %s", exists.path, path)
+		}
+		segments[segment.name] = segment
+	}
+	var heads []*auditSegment
+	for _, segment := range segments {
+		if _, ok := segments[segment.prevSegment]; !ok || segment.prevSegment == segment.name {
+			heads = append(heads, segment)
+			continue
+		}
+		if other, ok := next[segment.prevSegment]; ok {
+			return nil, fmt.Errorf("audit segments %s and %s both follow %s", other.path, segment.path, segment.prevSegment)
+		}
+		next[segment.prevSegment] = segment
+	}
+	if len(heads) != 1 {
+		var paths []string
+		for _, v := range heads {
+			paths = append(paths, v.path+" (after "+v.prevSegment+")")
+		}
+		sort.Strings(paths)
+		return nil, fmt.Errorf("audit chain broken, segments missing before: %s", strings.Join(paths, ", "))
+	}
+	var report = new(AuditReport)
+	for segment := heads[0]; segment != nil; segment = next[segment.name] {
+		if err := report.verify(segment, keys); err != nil {
+			return report, err
+		}
+		report.Segments = append(report.Segments, segment.path)
+	}
+	if len(report.Segments) != len(segments) {
+		return report, fmt.Errorf("audit chain broken, %d of %d segments linked", len(report.Segments), len(segments))
+	}
+	return report, nil
+}
+
+// verify 校验分段内每行哈希
+func (report *AuditReport) verify(segment *auditSegment, keys *KeyRing) error {
+	return readAuditLines(segment.path, keys, func(lineNo int, line string) error {
+		var body, chain, ok = splitAuditLine(line)
+		if !ok {
+			if IsSegmentMark(line) {
+				return nil
+			}
+			return fmt.Errorf("%s:%d: unchained audit line", segment.path, lineNo)
+		}
+		if strings.HasPrefix(body, auditCheckpoint+" ") {
+			var fields = parseTextLine(body)
+			if report.Anchor == "" {
+				report.Anchor, report.LastChain = fields["prev_chain"], fields["prev_chain"]
+			}
+			if fields["segment"] != segment.name {
+				return fmt.Errorf("%s:%d: checkpoint belongs to segment %s", segment.path, lineNo, fields["segment"])
+			}
+			if fields["prev_chain"] != report.LastChain {
+				return fmt.Errorf("%s:%d: checkpoint does not continue the chain, lines removed or reordered", segment.path, lineNo)
+			}
+		}
+		if report.LastChain == "" {
+			return fmt.Errorf("%s:%d: audit segment does not start with a checkpoint", segment.path, lineNo)
+		}
+		if auditHash(report.LastChain, body) != chain {
+			return fmt.Errorf("%s:%d: audit line modified, removed or reordered", segment.path, lineNo)
+		}
+		report.LastChain = chain
+		report.Lines++
+		return nil
+	})
+}
+
+// readAuditSegment 读取分段首个检查点
+func readAuditSegment(path string, keys *KeyRing) (*auditSegment, error) {
+	var segment = &auditSegment{path: path, name: segmentBaseName(path)}
+	var found = errors.New("found")
+	var err = readAuditLines(path, keys, func(lineNo int, line string) error {
+		var body, _, ok = splitAuditLine(line)
+		if !ok {
+			return nil
+		}
+		if !strings.HasPrefix(body, auditCheckpoint+" ") {
+			return fmt.Errorf("%s:%d: audit segment does not start with a checkpoint", path, lineNo)
+		}
+		segment.prevSegment = parseTextLine(body)["prev_segment"]
+		return found
+	})
+	if err != nil && err != found {
+		return nil, err
+	}
+	return segment, nil
+}
+
+func readAuditLines(name string, keys *KeyRing, fn func(lineNo int, line string) error) error {
+	var reader, err = OpenSegment(name, keys)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	var (
+		buf    = bufio.NewReader(reader)
+		lineNo int
+	)
+	for {
+		var line, err = buf.ReadString('\n')
+		if line != "" {
+			lineNo++
+			if err := fn(lineNo, strings.TrimRight(line, "\r\n")); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %v", name, err)
+		}
+	}
+}
+
+// splitAuditLine 拆分行内容与链哈希
+func splitAuditLine(line string) (string, string, bool) {
+	var index = strings.LastIndex(line, auditChainKey)
+	if index < 0 {
+		return line, "", false
+	}
+	var chain = line[index+len(auditChainKey):]
+	if len(chain) != len(auditGenesis) {
+		return line, "", false
+	}
+	if _, err := hex.DecodeString(chain); err != nil {
+		return line, "", false
+	}
+	return line[:index], chain, true
+}
+
+func auditHash(prev, line string) string {
+	var sum = sha256.Sum256([]byte(prev + "\n" + line))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package rotate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func writeAuditDays(t *testing.T, options *Options, clock *fakeClock, days int) {
+	var hook, err = NewAuditHook(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logger = log.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(hook)
+	for i := 0; i < days; i++ {
+		logger.WithField("user", "alice").Info("login")
+		logger.WithField("user", "bob").Warn("delete record")
+		clock.Add(24 * time.Hour)
+	}
+	if err = hook.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditHook_Verify(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+	)
+	options.Compress = true
+	writeAuditDays(t, options, clock, 3)
+	// 重启后从已有分段续接哈希链
+	clock.Add(-24 * time.Hour)
+	writeAuditDays(t, options, clock, 2)
+	report, err := VerifyAudit(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Segments) != 4 || report.Anchor != auditGenesis {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	var (
+		name       = filepath.Join(dir, "app-20210104.log")
+		content, _ = ioutil.ReadFile(name)
+		lines      = strings.SplitAfter(string(content), "\n")
+	)
+	var tamper = func(data string) error {
+		_ = ioutil.WriteFile(name, []byte(data), 0644)
+		_, err := VerifyAudit(options)
+		return err
+	}
+	if err = tamper(strings.Replace(string(content), "user=bob", "user=eve", 1)); err == nil {
+		t.Error("modified line not detected")
+	}
+	if err = tamper(lines[0] + lines[2] + lines[1] + strings.Join(lines[3:], "")); err == nil {
+		t.Error("reordered lines not detected")
+	}
+	if err = tamper(lines[0] + strings.Join(lines[2:], "")); err == nil {
+		t.Error("removed line not detected")
+	}
+	_ = ioutil.WriteFile(name, content, 0644)
+	if err = os.Remove(filepath.Join(dir, "app-20210102.log"+GzipExt)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = VerifyAudit(options); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("removed segment not detected: %v", err)
+	}
+}
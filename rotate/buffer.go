@@ -0,0 +1,153 @@
+package rotate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fsync 策略: never 不主动落盘, error 每条 error 及以上级别日志落盘, 其他值为落盘间隔 (eg: 5s, 5 表示 5 秒)
+const (
+	FsyncNever = "never"
+	FsyncError = "error"
+
+	defaultFlushInterval = time.Second
+)
+
+type (
+	// flusher 缓冲写入器, hook 按日志级别强制刷新
+	flusher interface {
+		Flush() error
+		Sync() error
+		syncFor(level log.Level) error
+	}
+)
+
+// parseFsync 解析 fsync 策略, 返回 never/error 或落盘间隔
+func parseFsync(value string) (string, time.Duration, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", FsyncNever:
+		return FsyncNever, 0, nil
+	case FsyncError:
+		return FsyncError, 0, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		return "", time.Duration(n) * time.Second, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return "", d, nil
+	}
+	return "", 0, fmt.Errorf("invalid fsync policy %q, expect never, error or an interval", value)
+}
+
+// initBuffer 按配置开启缓冲与定时刷新/落盘
+func (writer *rotateWriter) initBuffer(options *Options) error {
+	var policy, interval, err = parseFsync(options.Fsync)
+	if err != nil {
+		return err
+	}
+	writer.fsync, writer.fsyncInterval = policy, interval
+	if options.BufferSize > 0 {
+		if writer.shared {
+			return errors.New("buffered writes are not supported in shared mode")
+		}
+		writer.bufSize = options.BufferSize
+		writer.flushInterval = options.FlushInterval
+		if writer.flushInterval <= 0 {
+			writer.flushInterval = defaultFlushInterval
+		}
+	}
+	if writer.bufSize > 0 || writer.fsyncInterval > 0 {
+		writer.stop = make(chan struct{})
+		go writer.flushLoop(writer.stop)
+	}
+	return nil
+}
+
+// flushLoop 定时刷新缓冲与落盘, Close 时退出
+func (writer *rotateWriter) flushLoop(stop chan struct{}) {
+	var flush, sync <-chan time.Time
+	if writer.flushInterval > 0 {
+		var ticker = time.NewTicker(writer.flushInterval)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+	if writer.fsyncInterval > 0 {
+		var ticker = time.NewTicker(writer.fsyncInterval)
+		defer ticker.Stop()
+		sync = ticker.C
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-flush:
+			writer.report(writer.Flush())
+		case <-sync:
+			writer.report(writer.Sync())
+		}
+	}
+}
+
+// Flush 将缓冲写入当前文件
+func (writer *rotateWriter) Flush() error {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	return writer.flush()
+}
+
+// Sync 刷新缓冲并落盘
+func (writer *rotateWriter) Sync() error {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	if err := writer.flush(); err != nil {
+		return err
+	}
+	if writer.file == nil {
+		return nil
+	}
+	return writer.file.Sync()
+}
+
+// syncFor 按日志级别刷新: Fatal/Panic 强制刷新, fsync 策略为 error 时 error 及以上级别落盘
+func (writer *rotateWriter) syncFor(level log.Level) error {
+	if writer.fsync == FsyncError && level <= log.ErrorLevel {
+		return writer.Sync()
+	}
+	if level <= log.FatalLevel {
+		return writer.Flush()
+	}
+	return nil
+}
+
+func (writer *rotateWriter) flush() error {
+	if writer.buf == nil || writer.buf.Buffered() == 0 {
+		return nil
+	}
+	return writer.buf.Flush()
+}
+
+// resetBuffer 切换文件后缓冲指向新文件, 调用前需已刷新
+func (writer *rotateWriter) resetBuffer() {
+	if writer.bufSize <= 0 || writer.file == nil {
+		return
+	}
+	if writer.buf == nil {
+		writer.buf = bufio.NewWriterSize(writer.file, writer.bufSize)
+		return
+	}
+	writer.buf.Reset(writer.file)
+}
+
+// stopBuffer 停止定时刷新
+func (writer *rotateWriter) stopBuffer() {
+	if writer.stop != nil {
+		close(writer.stop)
+		writer.stop = nil
+	}
+}
@@ -0,0 +1,138 @@
+package rotate
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestParseFsync(t *testing.T) {
+	var cases = []struct {
+		value    string
+		policy   string
+		interval time.Duration
+	}{
+		{value: "", policy: FsyncNever},
+		{value: "ERROR", policy: FsyncError},
+		{value: "5", interval: 5 * time.Second},
+		{value: "500ms", interval: 500 * time.Millisecond},
+	}
+	for _, v := range cases {
+		var policy, interval, err = parseFsync(v.value)
+		if err != nil || policy != v.policy || interval != v.interval {
+			t.Errorf("%q: got %q %v %v", v.value, policy, interval, err)
+		}
+	}
+	if _, _, err := parseFsync("always"); err == nil {
+		t.Error("expect invalid fsync policy error")
+	}
+}
+
+func TestRotateHook_Buffered(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+		logger  = log.New()
+		first   = filepath.Join(dir, "app-20210101.log")
+	)
+	options.Level = "info"
+	options.BufferSize = 64 << 10
+	options.FlushInterval = time.Hour
+	hook, err := CreateRotateFactory().Create(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(hook)
+	logger.WithTime(clock.Now()).Warn("buffered")
+	if content, _ := ioutil.ReadFile(first); len(content) != 0 {
+		t.Errorf("expect buffered entry, file has %q", content)
+	}
+	// 切换分段前刷新到原分段
+	clock.Add(24 * time.Hour)
+	logger.WithTime(clock.Now()).Warn("next day")
+	if content, _ := ioutil.ReadFile(first); !strings.Contains(string(content), "buffered") {
+		t.Errorf("expect flush on rotation, file has %q", content)
+	}
+	// Fatal 级别强制刷新 (直接触发 hook, 避免进程退出)
+	var entry = log.NewEntry(logger).WithTime(clock.Now())
+	entry.Level, entry.Message = log.FatalLevel, "exit"
+	if err = hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	var content, _ = ioutil.ReadFile(filepath.Join(dir, "app-20210102.log"))
+	if !strings.Contains(string(content), "next day") || !strings.Contains(string(content), "exit") {
+		t.Errorf("expect flush on fatal, file has %q", content)
+	}
+}
+
+func benchmarkRotateWriter(b *testing.B, configure func(options *Options)) {
+	var (
+		options = CreateOptionsWithLogName(filepath.Join(b.TempDir(), "app.log"))
+		line    = []byte(`time="2021-01-01T10:00:00Z" level=info msg="request served" method=GET path=/api/v1/users status=200` + "\n")
+	)
+	configure(options)
+	var writer, err = newRotateWriter(options)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer writer.Close()
+	b.SetBytes(int64(len(line)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = writer.Write(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRotateWriter_Direct(b *testing.B) {
+	benchmarkRotateWriter(b, func(options *Options) {})
+}
+
+func BenchmarkRotateWriter_Buffered(b *testing.B) {
+	benchmarkRotateWriter(b, func(options *Options) {
+		options.BufferSize = 64 << 10
+	})
+}
+
+func BenchmarkRotateWriter_BufferedFsyncInterval(b *testing.B) {
+	benchmarkRotateWriter(b, func(options *Options) {
+		options.BufferSize = 64 << 10
+		options.Fsync = "1s"
+	})
+}
+
+func benchmarkRotateHook(b *testing.B, bufferSize int) {
+	var (
+		options = CreateOptionsWithLogName(filepath.Join(b.TempDir(), "app.log"))
+		logger  = log.New()
+	)
+	options.Level = "info"
+	options.BufferSize = bufferSize
+	hook, err := CreateRotateFactory().Create(options)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer hook.(io.Closer).Close()
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(hook)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.WithField("status", 200).Warn("request served")
+	}
+}
+
+func BenchmarkRotateHook_Direct(b *testing.B) {
+	benchmarkRotateHook(b, 0)
+}
+
+func BenchmarkRotateHook_Buffered(b *testing.B) {
+	benchmarkRotateHook(b, 64<<10)
+}
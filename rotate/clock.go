@@ -0,0 +1,33 @@
+package rotate
+
+import "time"
+
+type (
+	// Clock 时钟接口, 用于计算日志分割时间 (测试可注入)
+	Clock interface {
+		Now() time.Time
+	}
+
+	clockFn func() time.Time
+)
+
+var (
+	// Local 本地时区时钟
+	Local Clock = clockFn(time.Now)
+	// UTC 时区时钟
+	UTC Clock = clockFn(func() time.Time {
+		return time.Now().UTC()
+	})
+)
+
+// ClockFunc 函数转换为时钟
+func ClockFunc(fn func() time.Time) Clock {
+	if fn == nil {
+		return Local
+	}
+	return clockFn(fn)
+}
+
+func (fn clockFn) Now() time.Time {
+	return fn()
+}
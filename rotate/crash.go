@@ -0,0 +1,337 @@
+package rotate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 崩溃捕获: 将进程 fd 2 (可选 fd 1) dup2 到按日分割的崩溃文件, runtime panic 与 fatal error 直接写入文件,
+// 启动/正常关闭时写入标记行, 下次启动时若上次运行未正常关闭且输出含崩溃特征则视为崩溃
+const (
+	crashStartMark = "=== crash capture start"
+	crashStopMark  = "=== crash capture stop"
+
+	defaultCrashTailLines = 50
+	crashCheckInterval    = time.Second
+	crashTeeInterval      = 200 * time.Millisecond
+)
+
+var (
+	// crashSignatures Go 运行时崩溃输出特征, 未正常关闭但不含这些输出 (如直接 os.Exit) 不视为崩溃
+	crashSignatures = []string{"panic:", "fatal error:", "SIGSEGV"}
+)
+
+type (
+	// CrashCapture 标准错误重定向, Close 时恢复
+	CrashCapture struct {
+		writer   *rotateWriter
+		stdout   bool
+		original *os.File
+		stdoutFd *os.File
+		report   *CrashReport
+		err      error
+		stop     chan struct{}
+		waiter   sync.WaitGroup
+		tee      *os.File
+		teeName  string
+	}
+
+	// CrashReport 上次运行崩溃信息, Tail 为崩溃文件末尾输出
+	CrashReport struct {
+		File    string    `json:"file"`
+		Pid     int       `json:"pid"`
+		StartAt time.Time `json:"start_at"`
+		Tail    []string  `json:"tail"`
+	}
+)
+
+var (
+	errRedirectUnsupported = errors.New("stderr redirect (dup2) unsupported on this platform")
+
+	crashLocker sync.Mutex
+	crashActive *CrashCapture
+)
+
+// CaptureCrash 重定向标准错误到 CrashLogName 分段, 同一进程只能开启一次
+func CaptureCrash(options *Options) (*CrashCapture, error) {
+	if !redirectSupported {
+		return nil, errRedirectUnsupported
+	}
+	if options == nil || options.CrashLogName == "" {
+		return nil, errors.New("crash log name missing")
+	}
+	crashLocker.Lock()
+	defer crashLocker.Unlock()
+	if crashActive != nil {
+		return nil, errors.New("stderr is already captured")
+	}
+	var writer, err = newRotateWriter(options.forCrash())
+	if err != nil {
+		return nil, err
+	}
+	var capture = new(CrashCapture)
+	capture.writer = writer
+	capture.stdout = options.CrashStdout
+	capture.report = findCrash(writer, options.CrashTailLines)
+	if capture.original, err = dupFile(int(os.Stderr.Fd()), "stderr"); err != nil {
+		_ = writer.Close()
+		return nil, err
+	}
+	if capture.stdout {
+		if capture.stdoutFd, err = dupFile(int(os.Stdout.Fd()), "stdout"); err != nil {
+			_ = capture.original.Close()
+			_ = writer.Close()
+			return nil, err
+		}
+	}
+	writer.onOpen = capture.redirect
+	if _, err = writer.Write([]byte(crashMark(crashStartMark, writer.clock.Now()))); err == nil {
+		err = capture.err
+	}
+	if err != nil {
+		capture.restore()
+		_ = writer.Close()
+		return nil, err
+	}
+	if options.CrashTee {
+		capture.teeName = writer.CurrentFileName()
+		if capture.tee, err = os.Open(capture.teeName); err == nil {
+			_, err = capture.tee.Seek(0, io.SeekEnd)
+		}
+		capture.warn(err)
+		// 定时转发赶不上 panic 后的进程退出, 崩溃输出由运行时同步写入原标准错误
+		capture.warn(setCrashOutput(capture.original))
+	}
+	capture.stop = make(chan struct{})
+	capture.waiter.Add(1)
+	go capture.loop(capture.stop)
+	crashActive = capture
+	capture.fire(options.GetCrashHook())
+	return capture, nil
+}
+
+// Report 上次运行的崩溃信息, 未崩溃时为 nil
+func (capture *CrashCapture) Report() *CrashReport {
+	return capture.report
+}
+
+// Close 写入正常关闭标记并恢复标准错误
+func (capture *CrashCapture) Close() error {
+	crashLocker.Lock()
+	defer crashLocker.Unlock()
+	if capture.stop == nil {
+		return nil
+	}
+	close(capture.stop)
+	capture.stop = nil
+	capture.waiter.Wait()
+	var _, err = capture.writer.Write([]byte(crashMark(crashStopMark, capture.writer.clock.Now())))
+	capture.copyTee()
+	if capture.tee != nil {
+		_ = capture.tee.Close()
+	}
+	capture.restore()
+	if e := capture.writer.Close(); err == nil {
+		err = e
+	}
+	if crashActive == capture {
+		crashActive = nil
+	}
+	return err
+}
+
+// redirect 打开新分段后将 fd 2 (及 fd 1) 指向该分段, 写入器锁内调用
+func (capture *CrashCapture) redirect(previous, name string) {
+	var fd = int(capture.writer.file.Fd())
+	if err := redirectFd(fd, int(os.Stderr.Fd())); err != nil {
+		capture.err = fmt.Errorf("redirect stderr to %s: %v", name, err)
+		return
+	}
+	if capture.stdout {
+		if err := redirectFd(fd, int(os.Stdout.Fd())); err != nil {
+			capture.err = fmt.Errorf("redirect stdout to %s: %v", name, err)
+		}
+	}
+}
+
+func (capture *CrashCapture) restore() {
+	if capture.original != nil {
+		if capture.tee != nil {
+			_ = setCrashOutput(nil)
+		}
+		_ = redirectFd(int(capture.original.Fd()), int(os.Stderr.Fd()))
+		_ = capture.original.Close()
+		capture.original = nil
+	}
+	if capture.stdoutFd != nil {
+		_ = redirectFd(int(capture.stdoutFd.Fd()), int(os.Stdout.Fd()))
+		_ = capture.stdoutFd.Close()
+		capture.stdoutFd = nil
+	}
+}
+
+// loop 定时按时间/大小切换崩溃分段并转发输出到原标准错误
+func (capture *CrashCapture) loop(stop chan struct{}) {
+	defer capture.waiter.Done()
+	var (
+		check = time.NewTicker(crashCheckInterval)
+		tee   <-chan time.Time
+	)
+	defer check.Stop()
+	if capture.tee != nil {
+		var ticker = time.NewTicker(crashTeeInterval)
+		defer ticker.Stop()
+		tee = ticker.C
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-check.C:
+			capture.refresh()
+		case <-tee:
+			capture.copyTee()
+		}
+	}
+}
+
+// refresh 直接写入 fd 的数据不经过写入器, 切换判断前同步文件大小
+func (capture *CrashCapture) refresh() {
+	var writer = capture.writer
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	if writer.file != nil {
+		if info, err := writer.file.Stat(); err == nil {
+			writer.size = info.Size()
+		}
+	}
+	writer.report(writer.prepare(0))
+	capture.warn(capture.err)
+	capture.err = nil
+}
+
+// copyTee 转发新增输出到原标准错误, 分段切换后读完旧分段再读新分段
+func (capture *CrashCapture) copyTee() {
+	if capture.tee == nil || capture.original == nil {
+		return
+	}
+	var current = capture.writer.CurrentFileName()
+	_, _ = io.Copy(capture.original, capture.tee)
+	if current == "" || current == capture.teeName {
+		return
+	}
+	var file, err = os.Open(current)
+	if err != nil {
+		capture.warn(err)
+		return
+	}
+	_ = capture.tee.Close()
+	capture.tee, capture.teeName = file, current
+	_, _ = io.Copy(capture.original, capture.tee)
+}
+
+// warn 输出到原标准错误, 避免写入崩溃文件
+func (capture *CrashCapture) warn(err error) {
+	if err == nil {
+		return
+	}
+	var out = capture.original
+	if out == nil {
+		out = os.Stderr
+	}
+	_, _ = fmt.Fprintf(out, "rotate: %v\n", err)
+}
+
+// fire 通过 hook 上报上次运行崩溃
+func (capture *CrashCapture) fire(hook log.Hook) {
+	var report = capture.report
+	if report == nil || hook == nil {
+		return
+	}
+	var entry = log.NewEntry(log.StandardLogger()).WithFields(log.Fields{
+		"crash_file":     report.File,
+		"crash_pid":      report.Pid,
+		"crash_start_at": report.StartAt,
+		"crash_tail":     strings.Join(report.Tail, "\n"),
+	})
+	entry.Level = log.ErrorLevel
+	entry.Message = fmt.Sprintf("previous run (pid %d) crashed, see %s", report.Pid, report.File)
+	entry.Time = capture.writer.clock.Now()
+	capture.warn(hook.Fire(entry))
+}
+
+// findCrash 检查最近的崩溃分段: 最后一次启动后没有正常关闭标记且输出含崩溃特征时返回崩溃信息
+func findCrash(writer *rotateWriter, tailLines int) *CrashReport {
+	var segments = writer.match(writer.globPattern, "")
+	if len(segments) == 0 {
+		return nil
+	}
+	if tailLines <= 0 {
+		tailLines = defaultCrashTailLines
+	}
+	var path = segments[len(segments)-1].path
+	reader, err := OpenSegment(path, writer.keys)
+	if err != nil {
+		writer.report(err)
+		return nil
+	}
+	defer reader.Close()
+	var (
+		report  *CrashReport
+		crashed bool
+		scanner = bufio.NewScanner(reader)
+	)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var line = scanner.Text()
+		switch {
+		case strings.HasPrefix(line, crashStartMark):
+			var fields = parseTextLine(strings.Trim(line[len(crashStartMark):], " ="))
+			report, crashed = &CrashReport{File: path}, false
+			report.Pid, _ = strconv.Atoi(fields["pid"])
+			report.StartAt, _ = time.Parse(time.RFC3339, fields["time"])
+		case strings.HasPrefix(line, crashStopMark):
+			report = nil
+		case report != nil:
+			crashed = crashed || isCrashLine(line)
+			report.Tail = append(report.Tail, line)
+			if len(report.Tail) > tailLines {
+				report.Tail = report.Tail[1:]
+			}
+		}
+	}
+	if report == nil || !crashed {
+		return nil
+	}
+	return report
+}
+
+func isCrashLine(line string) bool {
+	for _, v := range crashSignatures {
+		if strings.Contains(line, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func crashMark(mark string, now time.Time) string {
+	return fmt.Sprintf("%s pid=%d time=%s ===\n", mark, os.Getpid(), now.Format(time.RFC3339))
+}
+
+func dupFile(fd int, name string) (*os.File, error) {
+	var dup, err = dupFd(fd)
+	if err != nil {
+		return nil, fmt.Errorf("dup %s: %v", name, err)
+	}
+	return os.NewFile(uintptr(dup), name), nil
+}
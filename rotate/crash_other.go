@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package rotate
+
+const redirectSupported = false
+
+func dupFd(fd int) (int, error) {
+	return -1, errRedirectUnsupported
+}
+
+func redirectFd(from, to int) error {
+	return errRedirectUnsupported
+}
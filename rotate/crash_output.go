@@ -0,0 +1,17 @@
+//go:build go1.23
+// +build go1.23
+
+package rotate
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+// crashOutputSupported 运行时崩溃输出 (panic/fatal error) 是否可同步写入原标准错误
+const crashOutputSupported = true
+
+// setCrashOutput 运行时崩溃输出在写入 fd 2 (崩溃文件) 的同时写入 file, 退出前即已到达原标准错误; file 为 nil 时取消
+func setCrashOutput(file *os.File) error {
+	return debug.SetCrashOutput(file, debug.CrashOptions{})
+}
@@ -0,0 +1,13 @@
+//go:build !go1.23
+// +build !go1.23
+
+package rotate
+
+import "os"
+
+// crashOutputSupported 低版本运行时不支持 debug.SetCrashOutput, 崩溃输出只能由定时转发送达原标准错误
+const crashOutputSupported = false
+
+func setCrashOutput(file *os.File) error {
+	return nil
+}
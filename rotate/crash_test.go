@@ -0,0 +1,129 @@
+package rotate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const crashHelperEnv = "ROTATE_CRASH_HELPER"
+
+// TestCrashHelper 子进程入口, 由 TestCaptureCrash 启动
+func TestCrashHelper(t *testing.T) {
+	var dir = os.Getenv(crashHelperEnv)
+	if dir == "" {
+		t.Skip("helper process only")
+	}
+	var (
+		options = CreateOptionsWithLogName(filepath.Join(dir, "app.log"))
+		hook    = new(recordHook)
+	)
+	options.CrashLogName = filepath.Join(dir, "crash.log")
+	options.CrashTee = true
+	options.SetCrashHook(hook)
+	capture, err := CaptureCrash(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv(crashHelperEnv+"_MODE") == "report" {
+		var bytes, _ = json.Marshal(capture.Report())
+		_ = ioutil.WriteFile(filepath.Join(dir, "report.json"), bytes, 0644)
+		if reported := capture.Report() != nil; reported != (len(hook.entries) == 1) {
+			t.Errorf("crash report entries %d, report %v", len(hook.entries), reported)
+		}
+		_ = capture.Close()
+		return
+	}
+	if os.Getenv(crashHelperEnv+"_MODE") == "exit" {
+		// 未调用 Close 直接退出, 不应视为崩溃
+		fmt.Fprintln(os.Stderr, "shutting down")
+		time.Sleep(3 * crashTeeInterval)
+		os.Exit(0)
+	}
+	fmt.Fprintln(os.Stderr, "before panic")
+	time.Sleep(3 * crashTeeInterval)
+	panic("boom")
+}
+
+func runCrashHelper(t *testing.T, dir, mode string) (string, error) {
+	var (
+		stderr bytes.Buffer
+		cmd    = exec.Command(os.Args[0], "-test.run=^TestCrashHelper$")
+	)
+	cmd.Env = append(os.Environ(), crashHelperEnv+"="+dir, crashHelperEnv+"_MODE="+mode)
+	cmd.Stderr = &stderr
+	var err = cmd.Run()
+	return stderr.String(), err
+}
+
+func TestCaptureCrash(t *testing.T) {
+	if !redirectSupported {
+		t.Skip(errRedirectUnsupported)
+	}
+	var dir = t.TempDir()
+	stderr, err := runCrashHelper(t, dir, "panic")
+	if err == nil {
+		t.Fatal("expect helper process to crash")
+	}
+	if !strings.Contains(stderr, "before panic") {
+		t.Errorf("expect tee to original stderr, got %q", stderr)
+	}
+	if crashOutputSupported && !strings.Contains(stderr, "panic: boom") {
+		t.Errorf("expect crash trace on original stderr before exit, got %q", stderr)
+	}
+	var name = filepath.Join(dir, "crash-"+time.Now().Format("20060102")+".log")
+	content, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "panic: boom") || !strings.Contains(string(content), "goroutine ") {
+		t.Errorf("panic trace not captured: %q", content)
+	}
+	if _, err = runCrashHelper(t, dir, "report"); err != nil {
+		t.Fatalf("report helper failed: %v", err)
+	}
+	var report CrashReport
+	bytes, _ := ioutil.ReadFile(filepath.Join(dir, "report.json"))
+	if err = json.Unmarshal(bytes, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.File != name || report.Pid == 0 || !strings.Contains(strings.Join(report.Tail, "\n"), "panic: boom") {
+		t.Errorf("unexpected crash report %+v", report)
+	}
+	// 正常关闭后不再上报
+	if _, err = runCrashHelper(t, dir, "report"); err != nil {
+		t.Fatal(err)
+	}
+	bytes, _ = ioutil.ReadFile(filepath.Join(dir, "report.json"))
+	if string(bytes) != "null" {
+		t.Errorf("expect no crash after clean exit, got %s", bytes)
+	}
+}
+
+func TestCaptureCrash_CleanExit(t *testing.T) {
+	if !redirectSupported {
+		t.Skip(errRedirectUnsupported)
+	}
+	var dir = t.TempDir()
+	if _, err := runCrashHelper(t, dir, "exit"); err != nil {
+		t.Fatalf("exit helper failed: %v", err)
+	}
+	var name = filepath.Join(dir, "crash-"+time.Now().Format("20060102")+".log")
+	if content, _ := ioutil.ReadFile(name); !strings.Contains(string(content), "shutting down") {
+		t.Fatalf("stderr not captured: %q", content)
+	}
+	if _, err := runCrashHelper(t, dir, "report"); err != nil {
+		t.Fatal(err)
+	}
+	var bytes, _ = ioutil.ReadFile(filepath.Join(dir, "report.json"))
+	if string(bytes) != "null" {
+		t.Errorf("expect no crash report without panic output, got %s", bytes)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package rotate
+
+import "golang.org/x/sys/unix"
+
+// redirectSupported 是否支持 dup2 重定向标准输出
+const redirectSupported = true
+
+// dupFd 复制文件描述符
+func dupFd(fd int) (int, error) {
+	return unix.Dup(fd)
+}
+
+// redirectFd 将 to 指向 from 打开的文件
+func redirectFd(from, to int) error {
+	return unix.Dup2(from, to)
+}
@@ -0,0 +1,232 @@
+package rotate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// diskState 磁盘剩余空间状态
+	diskState int
+
+	// diskThreshold 剩余空间阈值, 字节数或百分比
+	diskThreshold struct {
+		bytes   uint64
+		percent float64
+	}
+
+	// diskGuard 磁盘剩余空间守护, 空间不足时按级别丢弃日志
+	diskGuard struct {
+		locker    sync.Mutex
+		path      string
+		soft      *diskThreshold
+		hard      *diskThreshold
+		interval  time.Duration
+		clock     Clock
+		checkedAt time.Time
+		state     diskState
+		warnHook  log.Hook
+		statfs    func(path string) (free uint64, total uint64, err error)
+	}
+)
+
+const (
+	diskNormal diskState = iota
+	diskSoft
+	diskHard
+	defaultDiskCheckInterval = 10 * time.Second
+)
+
+var (
+	errStatfsUnsupported = errors.New("statfs unsupported on this platform")
+	sizeUnits            = []struct {
+		suffix string
+		size   uint64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+)
+
+func (state diskState) String() string {
+	switch state {
+	case diskSoft:
+		return "soft"
+	case diskHard:
+		return "hard"
+	}
+	return "normal"
+}
+
+// ParseSize 解析容量, eg: 512MB, 1G, 1024
+func ParseSize(value string) (uint64, error) {
+	var data = strings.ToUpper(strings.TrimSpace(value))
+	if data == "" {
+		return 0, nil
+	}
+	for _, unit := range sizeUnits {
+		if !strings.HasSuffix(data, unit.suffix) {
+			continue
+		}
+		var n, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(data, unit.suffix)), 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid size %q", value)
+		}
+		return uint64(n * float64(unit.size)), nil
+	}
+	var n, err = strconv.ParseUint(data, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return n, nil
+}
+
+// parseThreshold 解析阈值, eg: 10%, 512MB
+func parseThreshold(value string) (*diskThreshold, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if strings.HasSuffix(value, "%") {
+		var n, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || n < 0 || n > 100 {
+			return nil, fmt.Errorf("invalid disk threshold %q", value)
+		}
+		return &diskThreshold{percent: n}, nil
+	}
+	var n, err = ParseSize(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid disk threshold %q", value)
+	}
+	return &diskThreshold{bytes: n}, nil
+}
+
+func (threshold *diskThreshold) below(free, total uint64) bool {
+	if threshold == nil {
+		return false
+	}
+	if threshold.percent > 0 {
+		return total > 0 && float64(free)*100/float64(total) < threshold.percent
+	}
+	return free < threshold.bytes
+}
+
+// newDiskGuard 根据参数构建磁盘守护, 未配置阈值时返回 nil
+func newDiskGuard(options *Options) (*diskGuard, error) {
+	var soft, err = parseThreshold(options.DiskSoftFree)
+	if err != nil {
+		return nil, err
+	}
+	hard, err := parseThreshold(options.DiskHardFree)
+	if err != nil {
+		return nil, err
+	}
+	if soft == nil && hard == nil {
+		return nil, nil
+	}
+	pattern, err := options.GetGlobPattern()
+	if err != nil {
+		return nil, err
+	}
+	var guard = new(diskGuard)
+	guard.path = globBaseDir(pattern)
+	guard.soft = soft
+	guard.hard = hard
+	guard.interval = options.DiskCheckInterval
+	if guard.interval <= 0 {
+		guard.interval = defaultDiskCheckInterval
+	}
+	guard.clock = options.GetClock()
+	guard.warnHook = options.GetDiskWarnHook()
+	guard.statfs = statfs
+	return guard, nil
+}
+
+// globBaseDir glob 表达式中不含通配符的目录部分, eg: /var/log/*/app-*.log => /var/log
+func globBaseDir(pattern string) string {
+	if index := strings.IndexAny(pattern, "*?["); index >= 0 {
+		pattern = pattern[:index+1]
+	}
+	return filepath.Dir(pattern)
+}
+
+// Allow 当前磁盘状态下是否写入该级别日志
+func (guard *diskGuard) Allow(level log.Level) bool {
+	switch guard.check() {
+	case diskSoft:
+		return level <= log.WarnLevel
+	case diskHard:
+		return level <= log.ErrorLevel
+	}
+	return true
+}
+
+func (guard *diskGuard) check() diskState {
+	guard.locker.Lock()
+	var now = guard.clock.Now()
+	if !guard.checkedAt.IsZero() && now.Sub(guard.checkedAt) < guard.interval {
+		defer guard.locker.Unlock()
+		return guard.state
+	}
+	guard.checkedAt = now
+	var (
+		previous         = guard.state
+		free, total, err = guard.statfs(existingDir(guard.path))
+	)
+	if err != nil {
+		guard.locker.Unlock()
+		return previous
+	}
+	var state = diskNormal
+	if guard.hard.below(free, total) {
+		state = diskHard
+	} else if guard.soft.below(free, total) {
+		state = diskSoft
+	}
+	guard.state = state
+	guard.locker.Unlock()
+	if state != previous {
+		guard.warn(previous, state, free, total)
+	}
+	return state
+}
+
+// warn 状态切换时输出一次告警
+func (guard *diskGuard) warn(from, to diskState, free, total uint64) {
+	var msg = fmt.Sprintf("rotate: disk space state %s -> %s on %s (free %d of %d bytes)", from, to, guard.path, free, total)
+	if guard.warnHook == nil {
+		_, _ = fmt.Fprintln(os.Stderr, msg)
+		return
+	}
+	var entry = log.NewEntry(log.StandardLogger()).WithFields(log.Fields{
+		"path": guard.path, "from": from.String(), "to": to.String(), "free": free, "total": total,
+	})
+	entry.Level = log.WarnLevel
+	entry.Message = msg
+	entry.Time = guard.clock.Now()
+	if err := guard.warnHook.Fire(entry); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, msg)
+	}
+}
+
+// existingDir 最近的已存在目录
+func existingDir(path string) string {
+	for {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return path
+		}
+		var parent = filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
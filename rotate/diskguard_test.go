@@ -0,0 +1,91 @@
+package rotate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type recordHook struct {
+	entries []*log.Entry
+}
+
+func (hook *recordHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (hook *recordHook) Fire(entry *log.Entry) error {
+	hook.entries = append(hook.entries, entry)
+	return nil
+}
+
+func TestDiskGuard_Allow(t *testing.T) {
+	var (
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(t.TempDir(), clock)
+		warn    = new(recordHook)
+		free    uint64
+	)
+	options.DiskSoftFree = "20%"
+	options.DiskHardFree = "100MB"
+	options.DiskCheckInterval = time.Second
+	options.SetDiskWarnHook(warn)
+	guard, err := newDiskGuard(options)
+	if err != nil || guard == nil {
+		t.Fatalf("create disk guard failed: %v", err)
+	}
+	guard.statfs = func(string) (uint64, uint64, error) {
+		return free, 1 << 30, nil
+	}
+	var cases = []struct {
+		free  uint64
+		level log.Level
+		allow bool
+	}{
+		{free: 1 << 29, level: log.DebugLevel, allow: true},
+		{free: 150 << 20, level: log.InfoLevel, allow: false},
+		{free: 150 << 20, level: log.WarnLevel, allow: true},
+		{free: 50 << 20, level: log.WarnLevel, allow: false},
+		{free: 50 << 20, level: log.ErrorLevel, allow: true},
+		{free: 1 << 29, level: log.DebugLevel, allow: true},
+	}
+	for i, v := range cases {
+		free = v.free
+		clock.Add(2 * time.Second)
+		if guard.Allow(v.level) != v.allow {
+			t.Errorf("case %d: expect allow=%v", i, v.allow)
+		}
+	}
+	// normal -> soft -> hard -> normal
+	if len(warn.entries) != 3 {
+		t.Errorf("expect 3 transition warnings, got %d", len(warn.entries))
+	}
+	options.DiskSoftFree = "120%"
+	if _, err = newDiskGuard(options); err == nil {
+		t.Error("expect invalid threshold error")
+	}
+}
+
+func TestNewDiskGuard_SplitLevelPath(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		options = newTestOptions(dir, nil)
+	)
+	options.DiskHardFree = "100MB"
+	for _, layout := range []string{"%s-{level}-%Y%m%d.log", filepath.Join(dir, "{level}", "app-%Y%m%d.log")} {
+		options.LogNameLayout = layout
+		var guard, err = newDiskGuard(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if guard.path != dir {
+			t.Errorf("layout %s: expect guard path %s, got %s", layout, dir, guard.path)
+		}
+	}
+	options.LogNameLayout = "%s-{bogus}-%Y%m%d.log"
+	if _, err := newDiskGuard(options); err == nil {
+		t.Error("expect invalid placeholder error")
+	}
+}
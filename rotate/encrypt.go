@@ -0,0 +1,460 @@
+package rotate
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// 加密分段格式: 会话头 (encryptMagic | keyId 长度 1 字节 | keyId | nonce 前缀 8 字节) 后跟若干数据块
+// (chunkTag | 密文长度 4 字节 | AES-GCM 密文), nonce 为前缀 + 块序号, 追加写入时每个写入会话重新写会话头;
+// 会话以 finalTag 结束块收尾 (附加数据含结束标记), 正在写入或写入进程异常退出的分段末尾没有结束块
+const (
+	EncryptExt     = ".enc"
+	EncryptRotated = "rotated"
+	EncryptLive    = "live"
+
+	encryptMagic    = "LOGENC1\n"
+	chunkTag        = 'c'
+	finalTag        = 'f'
+	noncePrefixSize = 8
+	maxChunkSize    = 1 << 20
+	fileChunkSize   = 64 << 10
+)
+
+type (
+	// KeyRing 加密密钥集合, 第一个密钥用于加密, 其余用于解密历史分段
+	KeyRing struct {
+		active string
+		keys   map[string][]byte
+	}
+
+	// chunkSealer 分块加密
+	chunkSealer struct {
+		aead    cipher.AEAD
+		keyId   string
+		prefix  [noncePrefixSize]byte
+		counter uint32
+		started bool
+	}
+
+	// decryptReader 分块解密读取器
+	decryptReader struct {
+		reader   *bufio.Reader
+		keys     *KeyRing
+		aead     cipher.AEAD
+		keyId    string
+		prefix   []byte
+		counter  uint32
+		buf      []byte
+		done     bool // 当前会话已读到结束块
+		unsealed bool // 流末尾的会话没有结束块
+	}
+)
+
+var (
+	ErrKeyNotFound = errors.New("encryption key not found")
+)
+
+// ParseKeyRing 解析密钥, 以逗号或换行分隔, 格式 [id:][hex:|base64:]key, key 为 16/24/32 字节, # 开头为注释;
+// 未指定编码时 32/48/64 位纯十六进制按 hex 解析, 其他按 base64 解析
+func ParseKeyRing(text string) (*KeyRing, error) {
+	var keys = new(KeyRing)
+	keys.keys = make(map[string][]byte)
+	for _, v := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if v = strings.TrimSpace(v); v == "" || strings.HasPrefix(v, "#") {
+			continue
+		}
+		var id, encoded = "", v
+		if index := strings.Index(v, ":"); index > 0 && !hasKeyEncoding(v) {
+			id, encoded = strings.TrimSpace(v[:index]), strings.TrimSpace(v[index+1:])
+		}
+		var key, err = decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %v", id, err)
+		}
+		if id == "" {
+			var sum = sha256.Sum256(key)
+			id = hex.EncodeToString(sum[:4])
+		}
+		if err = keys.Add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	if keys.active == "" {
+		return nil, errors.New("no encryption key configured")
+	}
+	return keys, nil
+}
+
+// LoadKeyRing 读取密钥文件
+func LoadKeyRing(file string) (*KeyRing, error) {
+	var bytes, err = ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyRing(string(bytes))
+}
+
+const (
+	keyEncodingHex    = "hex:"
+	keyEncodingBase64 = "base64:"
+)
+
+func hasKeyEncoding(encoded string) bool {
+	return strings.HasPrefix(encoded, keyEncodingHex) || strings.HasPrefix(encoded, keyEncodingBase64)
+}
+
+// decodeKey 解码密钥; 32 位十六进制同时是合法的 base64 (24 字节), 因此未指定编码时纯十六进制优先按 hex 解析
+func decodeKey(encoded string) ([]byte, error) {
+	var (
+		key []byte
+		err error
+	)
+	switch {
+	case strings.HasPrefix(encoded, keyEncodingHex):
+		key, err = hex.DecodeString(strings.TrimSpace(strings.TrimPrefix(encoded, keyEncodingHex)))
+	case strings.HasPrefix(encoded, keyEncodingBase64):
+		key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(encoded, keyEncodingBase64)))
+	case isHexKey(encoded):
+		key, err = hex.DecodeString(encoded)
+	default:
+		key, err = base64.StdEncoding.DecodeString(encoded)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !validKeySize(len(key)) {
+		return nil, fmt.Errorf("key size %d, expect 16, 24 or 32 bytes", len(key))
+	}
+	return key, nil
+}
+
+func isHexKey(encoded string) bool {
+	if !validKeySize(len(encoded)/2) || len(encoded)%2 != 0 {
+		return false
+	}
+	for _, c := range encoded {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func validKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// Add 添加密钥, 首个密钥为当前加密密钥
+func (keys *KeyRing) Add(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("invalid encryption key id %q", id)
+	}
+	if !validKeySize(len(key)) {
+		return fmt.Errorf("key size %d, expect 16, 24 or 32 bytes", len(key))
+	}
+	if keys.keys == nil {
+		keys.keys = make(map[string][]byte)
+	}
+	if _, ok := keys.keys[id]; ok {
+		return fmt.Errorf("duplicated encryption key id %q", id)
+	}
+	keys.keys[id] = key
+	if keys.active == "" {
+		keys.active = id
+	}
+	return nil
+}
+
+// Active 当前加密密钥 id
+func (keys *KeyRing) Active() string {
+	if keys == nil {
+		return ""
+	}
+	return keys.active
+}
+
+func (keys *KeyRing) aead(id string) (cipher.AEAD, error) {
+	if keys == nil {
+		return nil, ErrKeyNotFound
+	}
+	var key, ok = keys.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func newChunkSealer(keys *KeyRing) (*chunkSealer, error) {
+	var aead, err = keys.aead(keys.Active())
+	if err != nil {
+		return nil, err
+	}
+	var sealer = &chunkSealer{aead: aead, keyId: keys.Active()}
+	return sealer, sealer.reset()
+}
+
+// reset 新会话: 重新生成 nonce 前缀并写会话头
+func (sealer *chunkSealer) reset() error {
+	if _, err := io.ReadFull(rand.Reader, sealer.prefix[:]); err != nil {
+		return err
+	}
+	sealer.counter = 0
+	sealer.started = false
+	return nil
+}
+
+// seal 加密数据, 首次调用带会话头, 返回可一次性追加的字节
+func (sealer *chunkSealer) seal(p []byte) ([]byte, error) {
+	var out []byte
+	for len(p) > 0 || out == nil {
+		if sealer.counter == ^uint32(0)-1 {
+			var end, err = sealer.final()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, end...)
+		}
+		if !sealer.started {
+			out = append(out, encryptMagic...)
+			out = append(out, byte(len(sealer.keyId)))
+			out = append(out, sealer.keyId...)
+			out = append(out, sealer.prefix[:]...)
+			sealer.started = true
+		}
+		var chunk = p
+		if len(chunk) > maxChunkSize {
+			chunk = chunk[:maxChunkSize]
+		}
+		p = p[len(chunk):]
+		out = append(out, sealer.chunk(chunkTag, chunk)...)
+	}
+	return out, nil
+}
+
+// final 会话结束块, 会话未开始时为空; 之后的写入开始新会话
+func (sealer *chunkSealer) final() ([]byte, error) {
+	if !sealer.started {
+		return nil, nil
+	}
+	var out = sealer.chunk(finalTag, nil)
+	return out, sealer.reset()
+}
+
+func (sealer *chunkSealer) chunk(tag byte, p []byte) []byte {
+	var (
+		nonce  = chunkNonce(sealer.prefix[:], sealer.counter)
+		sealed = sealer.aead.Seal(nil, nonce, p, chunkAad(sealer.keyId, tag))
+		size   [4]byte
+		out    = make([]byte, 0, 5+len(sealed))
+	)
+	sealer.counter++
+	binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+	out = append(out, tag)
+	out = append(out, size[:]...)
+	return append(out, sealed...)
+}
+
+// chunkAad 附加数据: keyId 与块类型, 结束块无法被替换为普通块 (反之亦然)
+func chunkAad(keyId string, tag byte) []byte {
+	return append([]byte(keyId), tag)
+}
+
+func chunkNonce(prefix []byte, counter uint32) []byte {
+	var nonce = make([]byte, noncePrefixSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	return nonce
+}
+
+// NewDecryptReader 解密分块加密流, 数据块被篡改, 调序或密钥缺失时返回错误;
+// 会话未结束即开始新会话 (写入进程异常退出后追加) 允许继续读取, 流末尾的会话缺少结束块或末尾块不完整时
+// 正常结束并标记为未封存, 见 IsUnsealed
+func NewDecryptReader(reader io.Reader, keys *KeyRing) io.Reader {
+	var buffered, ok = reader.(*bufio.Reader)
+	if !ok {
+		buffered = bufio.NewReader(reader)
+	}
+	return &decryptReader{reader: buffered, keys: keys}
+}
+
+// IsEncrypted 数据是否以加密会话头开始
+func IsEncrypted(reader *bufio.Reader) bool {
+	var magic, err = reader.Peek(len(encryptMagic))
+	return err == nil && string(magic) == encryptMagic
+}
+
+// IsUnsealed 读取结束后, 加密流末尾的会话是否缺少结束块 (分段正在写入或写入进程异常退出, 末尾数据可能不完整)
+func IsUnsealed(reader io.Reader) bool {
+	var v, ok = reader.(interface{ Unsealed() bool })
+	return ok && v.Unsealed()
+}
+
+// Unsealed 流末尾的会话缺少结束块
+func (reader *decryptReader) Unsealed() bool {
+	return reader.unsealed
+}
+
+func (reader *decryptReader) Read(p []byte) (int, error) {
+	for len(reader.buf) == 0 {
+		if err := reader.next(); err != nil {
+			return 0, err
+		}
+	}
+	var n = copy(p, reader.buf)
+	reader.buf = reader.buf[n:]
+	return n, nil
+}
+
+// next 读取下一个会话头或数据块
+func (reader *decryptReader) next() error {
+	var tag, err = reader.reader.ReadByte()
+	if err == io.EOF && reader.aead != nil && !reader.done {
+		return reader.eof()
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case tag == encryptMagic[0]:
+		return reader.session()
+	case reader.done:
+		return errors.New("encrypted chunk after end of session")
+	case (tag == chunkTag || tag == finalTag) && reader.aead != nil:
+		var size [4]byte
+		if _, err = io.ReadFull(reader.reader, size[:]); err != nil {
+			return reader.truncated(err)
+		}
+		var n = binary.BigEndian.Uint32(size[:])
+		if n > maxChunkSize+uint32(reader.aead.Overhead()) {
+			return fmt.Errorf("encrypted chunk too large: %d", n)
+		}
+		var sealed = make([]byte, n)
+		if _, err = io.ReadFull(reader.reader, sealed); err != nil {
+			return reader.truncated(err)
+		}
+		var nonce = chunkNonce(reader.prefix, reader.counter)
+		if reader.buf, err = reader.aead.Open(sealed[:0], nonce, sealed, chunkAad(reader.keyId, tag)); err != nil {
+			return fmt.Errorf("decrypt chunk %d: %v", reader.counter, err)
+		}
+		reader.counter++
+		reader.done = tag == finalTag
+		return nil
+	}
+	return errors.New("invalid encrypted segment")
+}
+
+func (reader *decryptReader) session() error {
+	var magic = make([]byte, len(encryptMagic)-1)
+	if _, err := io.ReadFull(reader.reader, magic); err != nil {
+		return reader.truncated(err)
+	} else if string(magic) != encryptMagic[1:] {
+		return errors.New("invalid encrypted segment header")
+	}
+	var size, err = reader.reader.ReadByte()
+	if err != nil {
+		return reader.truncated(err)
+	}
+	var header = make([]byte, int(size)+noncePrefixSize)
+	if _, err = io.ReadFull(reader.reader, header); err != nil {
+		return reader.truncated(err)
+	}
+	reader.keyId = string(header[:size])
+	if reader.aead, err = reader.keys.aead(reader.keyId); err != nil {
+		return err
+	}
+	reader.prefix = header[size:]
+	reader.counter = 0
+	reader.done = false
+	return nil
+}
+
+// truncated 流末尾的块或会话头不完整 (正在写入或写入进程异常退出), 丢弃并正常结束
+func (reader *decryptReader) truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return reader.eof()
+	}
+	return err
+}
+
+func (reader *decryptReader) eof() error {
+	reader.unsealed = true
+	return io.EOF
+}
+
+// encryptFile 加密已关闭分段为 name.enc 并删除原文件
+func encryptFile(name string, keys *KeyRing) error {
+	var sealer, err = newChunkSealer(keys)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	var tmpName = name + EncryptExt + ".tmp"
+	dst, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	var buf = make([]byte, fileChunkSize)
+	for {
+		var n, readErr = io.ReadFull(src, buf)
+		if n > 0 {
+			var sealed, err = sealer.seal(buf[:n])
+			if err == nil {
+				_, err = dst.Write(sealed)
+			}
+			if err != nil {
+				_ = dst.Close()
+				_ = os.Remove(tmpName)
+				return err
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			_ = dst.Close()
+			_ = os.Remove(tmpName)
+			return readErr
+		}
+	}
+	var end []byte
+	if end, err = sealer.final(); err == nil {
+		_, err = dst.Write(end)
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err = os.Rename(tmpName, name+EncryptExt); err != nil {
+		return err
+	}
+	_ = os.Chtimes(name+EncryptExt, info.ModTime(), info.ModTime())
+	return os.Remove(name)
+}
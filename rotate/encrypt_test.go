@@ -0,0 +1,162 @@
+package rotate
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestDecryptReader(t *testing.T) {
+	// k1 为 64 位十六进制, k3 为 32 位十六进制 (同时是合法 base64), 均应按 hex 解析
+	var (
+		hexKey1   = hex.EncodeToString(bytes.Repeat([]byte{1}, 32))
+		hexKey3   = hex.EncodeToString(bytes.Repeat([]byte{3}, 16))
+		keys, err = ParseKeyRing("k2:" + testKey(2) + ",k1:" + hexKey1 + ",k3:hex:" + hexKey3 + ",k4:" + hexKey3)
+	)
+	if err != nil || keys.Active() != "k2" {
+		t.Fatalf("parse key ring: %v", err)
+	}
+	if len(keys.keys["k1"]) != 32 || len(keys.keys["k4"]) != 16 {
+		t.Fatalf("hex keys decoded as base64: %d %d", len(keys.keys["k1"]), len(keys.keys["k4"]))
+	}
+	sealer, err := newChunkSealer(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stream []byte
+	for _, v := range []string{"first\n", "second\n"} {
+		var sealed, _ = sealer.seal([]byte(v))
+		stream = append(stream, sealed...)
+	}
+	end, _ := sealer.final()
+	stream = append(stream, end...)
+	// 追加新的会话 (密钥轮换后), 密钥以 base64 显式指定
+	old, _ := ParseKeyRing("k1:base64:" + testKey(1))
+	sealer, _ = newChunkSealer(old)
+	sealed, _ := sealer.seal([]byte("third\n"))
+	stream = append(stream, sealed...)
+	end, _ = sealer.final()
+	stream = append(stream, end...)
+	if bytes.Contains(stream, []byte("second")) {
+		t.Fatal("plaintext found in encrypted stream")
+	}
+	plain, err := ioutil.ReadAll(NewDecryptReader(bytes.NewReader(stream), keys))
+	if err != nil || string(plain) != "first\nsecond\nthird\n" {
+		t.Errorf("unexpected plaintext %q: %v", plain, err)
+	}
+	var reader = NewDecryptReader(bytes.NewReader(stream), keys)
+	if _, err = ioutil.ReadAll(reader); err != nil || IsUnsealed(reader) {
+		t.Errorf("sealed stream reported unsealed: %v", err)
+	}
+	// 缺少结束块或末尾块不完整 (正在写入/进程异常退出) 时正常结束并标记未封存
+	var cuts = map[int]string{
+		len(end):                           "first\nsecond\nthird\n",
+		len(end) + 3:                       "first\nsecond\n",
+		len(end) + 5 + len("third\n") + 16: "first\nsecond\n",
+	}
+	for cut, expect := range cuts {
+		var reader = NewDecryptReader(bytes.NewReader(stream[:len(stream)-cut]), keys)
+		plain, err = ioutil.ReadAll(reader)
+		if err != nil || string(plain) != expect || !IsUnsealed(reader) {
+			t.Errorf("unsealed stream (-%d bytes) got %q, unsealed %v: %v", cut, plain, IsUnsealed(reader), err)
+		}
+	}
+	// 文件中间的非法块类型
+	var invalid = append([]byte(nil), stream...)
+	invalid[len(encryptMagic)+1+len("k2")+noncePrefixSize] = 'x'
+	if _, err = ioutil.ReadAll(NewDecryptReader(bytes.NewReader(invalid), keys)); err == nil {
+		t.Error("invalid chunk tag not detected")
+	}
+	// 16 字节十六进制密钥加解密
+	var hexKeys, _ = ParseKeyRing("k3:hex:" + hexKey3)
+	sealer, _ = newChunkSealer(hexKeys)
+	sealed, _ = sealer.seal([]byte("hex\n"))
+	end, _ = sealer.final()
+	plain, err = ioutil.ReadAll(NewDecryptReader(bytes.NewReader(append(sealed, end...)), keys))
+	if err != nil || string(plain) != "hex\n" {
+		t.Errorf("hex key round trip %q: %v", plain, err)
+	}
+	stream[len(stream)-len(end)-3] ^= 0xff
+	if _, err = ioutil.ReadAll(NewDecryptReader(bytes.NewReader(stream), keys)); err == nil {
+		t.Error("tampered chunk not detected")
+	}
+	if _, err = ioutil.ReadAll(NewDecryptReader(bytes.NewReader(stream), old)); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expect missing key error, got %v", err)
+	}
+}
+
+func TestRotateWriter_Encrypt(t *testing.T) {
+	for _, mode := range []string{EncryptRotated, EncryptLive} {
+		var (
+			dir     = t.TempDir()
+			clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+			options = newTestOptions(dir, clock)
+			logger  = log.New()
+		)
+		options.Encrypt = mode
+		options.Compress = true
+		options.Level = "info"
+		options.EncryptKey = "k1:" + testKey(1)
+		hook, err := CreateRotateFactory().Create(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.SetOutput(ioutil.Discard)
+		logger.AddHook(hook)
+		for i := 0; i < 3; i++ {
+			logger.WithTime(clock.Now()).WithField("card", "4111").Warn("payment")
+			clock.Add(24 * time.Hour)
+		}
+		if mode == EncryptLive {
+			// 正在写入的分段没有结束块, 仍可检索
+			var count int
+			err = QueryLogs(options, Query{}, func(record *Record) bool {
+				count++
+				return true
+			})
+			if err != nil || count != 3 {
+				t.Errorf("query unsealed live segment got %d records: %v", count, err)
+			}
+		}
+		if err = hook.(*rotateHook).Close(); err != nil {
+			t.Fatal(err)
+		}
+		var matches, _ = filepath.Glob(filepath.Join(dir, "app-*"))
+		for _, name := range matches {
+			// rotated 模式当前分段切换后才加密
+			if mode == EncryptRotated && filepath.Base(name) == "app-20210103.log" {
+				continue
+			}
+			var content, _ = ioutil.ReadFile(name)
+			if strings.Contains(string(content), "4111") {
+				t.Errorf("%s: plaintext segment %s", mode, name)
+			}
+		}
+		if mode == EncryptRotated {
+			if _, err = os.Stat(filepath.Join(dir, "app-20210101.log"+GzipExt+EncryptExt)); err != nil {
+				t.Errorf("rotated segment not encrypted: %v", err)
+			}
+		}
+		var count int
+		err = QueryLogs(options, Query{}, func(record *Record) bool {
+			count++
+			return record.Fields["card"] == "4111"
+		})
+		if err != nil || count != 3 {
+			t.Errorf("%s: query encrypted segments got %d records: %v", mode, count, err)
+		}
+	}
+}
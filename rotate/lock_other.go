@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package rotate
+
+import "os"
+
+// sharedSupported 当前平台不支持 flock, 共享写入模式不可用
+const sharedSupported = false
+
+func lockFile(file *os.File, shared bool) error {
+	return errSharedUnsupported
+}
+
+func unlockFile(file *os.File) error {
+	return errSharedUnsupported
+}
+
+func isUnlinked(info os.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package rotate
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sharedSupported 是否支持多进程共享写入 (flock)
+const sharedSupported = true
+
+// lockFile flock 加锁, shared 为 true 时加共享锁
+func lockFile(file *os.File, shared bool) error {
+	var how = unix.LOCK_EX
+	if shared {
+		how = unix.LOCK_SH
+	}
+	for {
+		if err := unix.Flock(int(file.Fd()), how); err != unix.EINTR {
+			return err
+		}
+	}
+}
+
+func unlockFile(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
+
+// isUnlinked 文件是否已被删除 (其他进程压缩或清理)
+func isUnlinked(info os.FileInfo) bool {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Nlink == 0
+	}
+	return false
+}
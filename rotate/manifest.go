@@ -0,0 +1,177 @@
+package rotate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// Manifest 日志目录分段清单
+	Manifest struct {
+		Segments []*SegmentMeta `json:"segments"`
+	}
+
+	// SegmentMeta 分段元信息, 时间为首/末次写入时间
+	SegmentMeta struct {
+		Path       string    `json:"path"`
+		FirstAt    time.Time `json:"first_at"`
+		LastAt     time.Time `json:"last_at"`
+		Lines      int64     `json:"lines"`
+		Size       int64     `json:"size"`
+		Compressed bool      `json:"compressed"`
+		Closed     bool      `json:"closed"`
+		Archived   bool      `json:"archived"`
+		Encrypted  bool      `json:"encrypted"`
+	}
+)
+
+const (
+	ManifestName = ".manifest.json"
+)
+
+var (
+	manifestLocker sync.Mutex
+)
+
+// LoadManifest 读取日志目录清单, 清单不存在时返回空清单
+func LoadManifest(dir string) (*Manifest, error) {
+	manifestLocker.Lock()
+	defer manifestLocker.Unlock()
+	return loadManifest(dir)
+}
+
+func loadManifest(dir string) (*Manifest, error) {
+	var (
+		manifest   = new(Manifest)
+		bytes, err = ioutil.ReadFile(filepath.Join(dir, ManifestName))
+	)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(bytes, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// updateManifest 加锁读取-修改-原子写回清单
+func updateManifest(dir string, fn func(manifest *Manifest)) error {
+	manifestLocker.Lock()
+	defer manifestLocker.Unlock()
+	var manifest, err = loadManifest(dir)
+	if err != nil {
+		// 清单损坏时重建
+		manifest = new(Manifest)
+	}
+	fn(manifest)
+	sort.SliceStable(manifest.Segments, func(i, j int) bool {
+		return manifest.Segments[i].FirstAt.Before(manifest.Segments[j].FirstAt)
+	})
+	bytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	var (
+		name    = filepath.Join(dir, ManifestName)
+		tmpName = name + ".tmp"
+	)
+	if err = ioutil.WriteFile(tmpName, bytes, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, name)
+}
+
+// Get 按分段文件名查找
+func (manifest *Manifest) Get(name string) *SegmentMeta {
+	if manifest == nil {
+		return nil
+	}
+	var base = filepath.Base(name)
+	for _, v := range manifest.Segments {
+		if v.Path == base {
+			return v
+		}
+	}
+	return nil
+}
+
+func (manifest *Manifest) upsert(name string) *SegmentMeta {
+	if meta := manifest.Get(name); meta != nil {
+		return meta
+	}
+	var meta = &SegmentMeta{Path: filepath.Base(name)}
+	manifest.Segments = append(manifest.Segments, meta)
+	return meta
+}
+
+func (manifest *Manifest) remove(name string) {
+	var (
+		base     = filepath.Base(name)
+		segments = manifest.Segments[:0]
+	)
+	for _, v := range manifest.Segments {
+		if v.Path != base {
+			segments = append(segments, v)
+		}
+	}
+	manifest.Segments = segments
+}
+
+// manifestOpen 打开分段时登记清单
+func (writer *rotateWriter) manifestOpen(name string) {
+	writer.firstAt, writer.lastAt, writer.lines = time.Time{}, time.Time{}, 0
+	if !writer.manifest {
+		return
+	}
+	var err = updateManifest(filepath.Dir(name), func(manifest *Manifest) {
+		var meta = manifest.upsert(name)
+		meta.Size = writer.size
+		meta.Closed = false
+	})
+	writer.report(err)
+}
+
+// manifestSync 合并本写入器自上次同步以来的统计 (多进程共享写入时累加)
+func (writer *rotateWriter) manifestSync(name string, closed bool) {
+	if !writer.manifest || name == "" {
+		return
+	}
+	var err = updateManifest(filepath.Dir(name), func(manifest *Manifest) {
+		var meta = manifest.upsert(name)
+		if !writer.firstAt.IsZero() && (meta.FirstAt.IsZero() || writer.firstAt.Before(meta.FirstAt)) {
+			meta.FirstAt = writer.firstAt
+		}
+		if writer.lastAt.After(meta.LastAt) {
+			meta.LastAt = writer.lastAt
+		}
+		meta.Lines += writer.lines
+		meta.Size = writer.size
+		if info, err := os.Stat(name); err == nil {
+			meta.Size = info.Size()
+		}
+		meta.Closed = closed
+	})
+	writer.firstAt, writer.lastAt, writer.lines = time.Time{}, time.Time{}, 0
+	writer.report(err)
+}
+
+// manifestUpdate 修改已登记的分段信息
+func (writer *rotateWriter) manifestUpdate(name string, fn func(manifest *Manifest, meta *SegmentMeta)) {
+	if !writer.manifest {
+		return
+	}
+	var err = updateManifest(filepath.Dir(name), func(manifest *Manifest) {
+		if meta := manifest.Get(name); meta != nil {
+			fn(manifest, meta)
+		}
+	})
+	writer.report(err)
+}
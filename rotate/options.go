@@ -2,10 +2,12 @@ package rotate
 
 import (
 	"encoding/json"
-	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+	"fmt"
 	log "github.com/sirupsen/logrus"
+	"github.com/weblfe/logrus_hooks/entity"
 	"github.com/weblfe/logrus_hooks/utils"
 	"net/url"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -14,22 +16,81 @@ import (
 type (
 	// Options 构建hook 参数
 	Options struct {
-		RotationCount uint          `json:"rotation_count" yaml:"rotate_count" env:"rotate_count,20"`
-		DisableColors bool          `json:"disable_colors" yaml:"disable_colors" env:"disable_colors,false"`
-		LogNameLayout string        `json:"log_name_layout" yaml:"log_name_layout" env:"log_name_layout,%s-%Y%m%d.log"`
-		LogName       string        `json:"log_name" yaml:"log_name" env:"log_name,app"`
-		RotationTime  time.Duration `json:"rotation_time" yaml:"rotation_time" env:"rotation_time,24h"`
-		MaxAge        time.Duration `json:"max_age" yaml:"max_age" env:"max_age,0"`
-		Level         string        `json:"level" yaml:"level" env:"level,warn"`
-		optArr        []rotatelogs.Option
+		RotationCount uint          `json:"rotation_count" yaml:"rotate_count" env:"rotate_count,20" desc:"保留分段数量"`
+		DisableColors bool          `json:"disable_colors" yaml:"disable_colors" env:"disable_colors,true" desc:"关闭颜色输出, 默认关闭; 着色行 Query 仍可解析"`
+		LogNameLayout string        `json:"log_name_layout" yaml:"log_name_layout" env:"log_name_layout,%s-%Y%m%d.log" desc:"分段文件名格式, %s 为日志名, 支持 strftime 与 {level} 等占位符"`
+		LogName       string        `json:"log_name" yaml:"log_name" env:"log_name,app" desc:"日志文件名 (含目录)"`
+		RotationTime  time.Duration `json:"rotation_time" yaml:"rotation_time" env:"rotation_time,24h" desc:"按时间切换分段的间隔"`
+		MaxAge        time.Duration `json:"max_age" yaml:"max_age" env:"max_age,0" desc:"分段保留时长, 0 为不限"`
+		Level         string        `json:"level" yaml:"level" env:"level,warn" desc:"最低日志级别, 支持别名, 数值与阈值, eg: warning, 3, >=warn"`
+		RotationSize  int64         `json:"rotation_size" yaml:"rotation_size" env:"rotation_size,0" desc:"按大小切换分段 (字节), 0 为不限"`
+		Compress      bool          `json:"compress" yaml:"compress" env:"compress,false" desc:"切换后 gzip 压缩旧分段"`
+		MaxTotalSize  int64         `json:"max_total_size" yaml:"max_total_size" env:"max_total_size,0" desc:"分段总大小上限 (字节), 0 为不限"`
+		SplitLevels   bool          `json:"split_levels" yaml:"split_levels" env:"split_levels,false" desc:"按日志级别分文件"`
+		// LevelMaxAge 按日志级别保留时长, eg: {"error":"90d","debug":"72h"}, 设置后按级别分文件
+		LevelMaxAge map[string]string `json:"level_max_age" yaml:"level_max_age" env:"level_max_age" desc:"按级别保留时长 (json), eg: {\"error\":\"90d\"}"`
+		// DiskSoftFree 剩余空间低于该值丢弃 debug/info, DiskHardFree 低于该值只保留 error 及以上, eg: 10%, 512MB
+		DiskSoftFree      string        `json:"disk_soft_free" yaml:"disk_soft_free" env:"disk_soft_free" desc:"剩余空间低于该值丢弃 debug/info, eg: 10%, 512MB"`
+		DiskHardFree      string        `json:"disk_hard_free" yaml:"disk_hard_free" env:"disk_hard_free" desc:"剩余空间低于该值只保留 error 及以上"`
+		DiskCheckInterval time.Duration `json:"disk_check_interval" yaml:"disk_check_interval" env:"disk_check_interval,10s" desc:"磁盘空间检查间隔"`
+		// Mode rotate: 进程内分割, reopen: 固定文件追加写入, 由外部 logrotate 分割 (SIGHUP/SIGUSR1 重新打开)
+		Mode                string        `json:"mode" yaml:"mode" env:"mode,rotate,oneof=rotate|reopen" desc:"rotate: 进程内分割, reopen: 外部 logrotate 分割"`
+		ReopenCheckInterval time.Duration `json:"reopen_check_interval" yaml:"reopen_check_interval" env:"reopen_check_interval,1s" desc:"reopen 模式检查文件是否被移走的间隔"`
+		// ArchiveUrl 分段归档地址, eg: /data/archive, s3://bucket/prefix?endpoint=http://127.0.0.1:9000
+		ArchiveUrl    string `json:"archive_url" yaml:"archive_url" env:"archive_url" desc:"分段归档地址, eg: /data/archive, s3://bucket/prefix"`
+		ArchiveRemove bool   `json:"archive_remove" yaml:"archive_remove" env:"archive_remove,false" desc:"归档后删除本地分段"`
+		// RotateOnStartup 创建 hook 时强制切换到新分段
+		RotateOnStartup bool `json:"rotate_on_startup" yaml:"rotate_on_startup" env:"rotate_on_startup,false" desc:"启动时切换到新分段"`
+		// SessionNaming 文件名包含进程启动时间, eg: app-20210101-20210101T100000.log
+		SessionNaming bool `json:"session_naming" yaml:"session_naming" env:"session_naming,false" desc:"文件名包含进程启动时间"`
+		// Shared 多进程共享同一日志文件, flock 协调分段切换, 每条日志单次 O_APPEND 写入 (小于 PIPE_BUF 时不会交错)
+		Shared bool `json:"shared" yaml:"shared" env:"shared,false" desc:"多进程共享同一日志文件"`
+		// SegmentHeader 分段打开时写入头部 (服务, 版本, 主机, pid, 开始时间, schema 版本), 关闭时写入行数与校验和尾部
+		SegmentHeader  bool   `json:"segment_header" yaml:"segment_header" env:"segment_header,false" desc:"分段写入头部与尾部校验"`
+		ServiceName    string `json:"service_name" yaml:"service_name" env:"service_name" desc:"分段头部服务名"`
+		ServiceVersion string `json:"service_version" yaml:"service_version" env:"service_version" desc:"分段头部服务版本"`
+		SchemaVersion  string `json:"schema_version" yaml:"schema_version" env:"schema_version" desc:"分段头部 schema 版本"`
+		// Encrypt 分段 AES-GCM 分块加密: rotated 关闭后加密为 .enc, live 实时加密写入
+		Encrypt string `json:"encrypt" yaml:"encrypt" env:"encrypt,,oneof=rotated|live" desc:"分段加密模式: rotated 或 live"`
+		// EncryptKey 加密密钥, 格式 id:[hex:|base64:]key, 多个以逗号分隔, 第一个用于加密
+		EncryptKey     string `json:"encrypt_key" yaml:"encrypt_key" env:"encrypt_key" secret:"true" desc:"加密密钥 id:[hex:|base64:]key, 多个以逗号分隔"`
+		EncryptKeyFile string `json:"encrypt_key_file" yaml:"encrypt_key_file" env:"encrypt_key_file" desc:"加密密钥文件"`
+		// BufferSize 写缓冲大小 (字节), 0 为不缓冲; FlushInterval 定时刷新间隔; 仅 rotate 模式生效
+		BufferSize    int           `json:"buffer_size" yaml:"buffer_size" env:"buffer_size,0" desc:"写缓冲大小 (字节), 0 为不缓冲"`
+		FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval" env:"flush_interval,1s" desc:"缓冲定时刷新间隔"`
+		// Fsync 落盘策略: never, error (error 及以上级别日志落盘) 或落盘间隔, eg: 5s
+		Fsync string `json:"fsync" yaml:"fsync" env:"fsync,never" desc:"落盘策略: never, error 或落盘间隔"`
+		// CrashLogName 崩溃文件名, 设置后将标准错误 (runtime panic, fatal error) dup2 到按日分割的崩溃文件
+		CrashLogName string `json:"crash_log_name" yaml:"crash_log_name" env:"crash_log_name" desc:"崩溃文件名, 设置后捕获标准错误"`
+		// CrashStdout 同时重定向标准输出; CrashTee 同时转发到原标准错误 (定时转发, panic 等运行时崩溃输出在 go1.23 及以上同步写入)
+		CrashStdout bool `json:"crash_stdout" yaml:"crash_stdout" env:"crash_stdout,false" desc:"崩溃捕获同时重定向标准输出"`
+		CrashTee    bool `json:"crash_tee" yaml:"crash_tee" env:"crash_tee,false" desc:"崩溃捕获同时转发到原标准错误"`
+		// CrashTailLines 上次运行崩溃时上报的末尾行数
+		CrashTailLines int `json:"crash_tail_lines" yaml:"crash_tail_lines" env:"crash_tail_lines,50" desc:"上报上次崩溃的末尾行数"`
+		// Manifest 在日志目录维护分段清单 (.manifest.json), 供 Query 按时间范围跳过分段; 默认关闭,
+		// 开启后每次打开/关闭分段都会加锁改写清单, 未开启时 Query 检索全部分段
+		Manifest     bool `json:"manifest" yaml:"manifest" env:"manifest,false" desc:"维护分段清单 (.manifest.json), 加速按时间查询"`
+		clock        Clock
+		onRemove     RemoveHandler
+		diskWarnHook log.Hook
+		archiver     Archiver
+		keys         *KeyRing
+		crashHook    log.Hook
+		level        string
 	}
-
 )
 
+// NewOption 按参数构建 Options, 忽略解析/校验错误, 错误见 NewOptionE
 func NewOption(data ...interface{}) *Options {
+	var opt, _ = NewOptionE(data...)
+	return opt
+}
+
+// NewOptionE 按参数构建 Options, 从环境变量读取时返回解析/校验错误 (utils.EnvErrors)
+func NewOptionE(data ...interface{}) (*Options, error) {
 	var options = new(Options)
 	if len(data) <= 0 {
-		return CreateOptionsWithEnv(utils.UpperCase)
+		return CreateOptionsWithEnvE(utils.UpperCase)
 	}
 	var (
 		arg     = data[0]
@@ -41,7 +102,7 @@ func NewOption(data ...interface{}) *Options {
 			var bytes = arg.([]byte)
 			if json.Valid(bytes) {
 				if err := json.Unmarshal(bytes, options); err == nil {
-					return options
+					return options, nil
 				}
 			}
 			arg = string(bytes)
@@ -50,13 +111,13 @@ func NewOption(data ...interface{}) *Options {
 			through = false
 			var key = arg.(string)
 			if key == "" {
-				return CreateOptionsWithEnv(utils.UpperCase)
+				return CreateOptionsWithEnvE(utils.UpperCase)
 			}
 			// eg: case=1&prefix=app_&suffix=_logger
 			if strings.Contains(key, "=") {
 				var values, err = url.ParseQuery(key)
 				if err != nil {
-					return CreateOptionsWithEnv(utils.UpperCase)
+					return CreateOptionsWithEnvE(utils.UpperCase)
 				}
 				var (
 					prefix, suffix string
@@ -99,20 +160,20 @@ func NewOption(data ...interface{}) *Options {
 				if suffix != "" {
 					args[1] = suffix
 				}
-				return CreateOptionsWithEnv(caseMode, args...)
+				return CreateOptionsWithEnvE(caseMode, args...)
 			}
 			// eg: app_,_logger
 			if strings.Contains(key, ",") {
 				var kArr = strings.Split(key, ",")
-				return CreateOptionsWithEnv(utils.UpperCase, kArr...)
+				return CreateOptionsWithEnvE(utils.UpperCase, kArr...)
 			}
 			// namespace prefix
 			if !strings.Contains(key, "_") {
 				key = key + "_"
 			}
-			CreateOptionsWithEnv(utils.UpperCase, key)
+			return CreateOptionsWithEnvE(utils.UpperCase, key)
 		case *Options:
-			return arg.(*Options)
+			return arg.(*Options), nil
 		default:
 			break
 		}
@@ -120,33 +181,229 @@ func NewOption(data ...interface{}) *Options {
 			break
 		}
 	}
-	return CreateOptionsWithEnv(utils.UpperCase)
+	return CreateOptionsWithEnvE(utils.UpperCase)
+}
+
+// SetClock 设置分割时间计算时钟 (默认本地时钟)
+func (option *Options) SetClock(clock Clock) *Options {
+	option.clock = clock
+	return option
+}
+
+func (option *Options) GetClock() Clock {
+	if option.clock == nil {
+		return Local
+	}
+	return option.clock
+}
+
+// SetRemoveHandler 设置历史分段删除回调, 未设置时输出到 stderr
+func (option *Options) SetRemoveHandler(handler RemoveHandler) *Options {
+	option.onRemove = handler
+	return option
+}
+
+func (option *Options) GetRemoveHandler() RemoveHandler {
+	return option.onRemove
+}
+
+// SetDiskWarnHook 设置磁盘状态切换告警输出 hook, 未设置时输出到 stderr
+func (option *Options) SetDiskWarnHook(hook log.Hook) *Options {
+	option.diskWarnHook = hook
+	return option
+}
+
+func (option *Options) GetDiskWarnHook() log.Hook {
+	return option.diskWarnHook
+}
+
+// SetArchiver 设置分段归档器, 优先于 ArchiveUrl
+func (option *Options) SetArchiver(archiver Archiver) *Options {
+	option.archiver = archiver
+	return option
+}
+
+func (option *Options) GetArchiver() (Archiver, error) {
+	if option.archiver != nil {
+		return option.archiver, nil
+	}
+	return NewArchiver(option.ArchiveUrl)
+}
+
+// SetCrashHook 设置上次运行崩溃的上报 hook
+func (option *Options) SetCrashHook(hook log.Hook) *Options {
+	option.crashHook = hook
+	return option
 }
 
-func (option *Options) parse() []rotatelogs.Option {
-	if option.optArr != nil {
-		return option.optArr
+func (option *Options) GetCrashHook() log.Hook {
+	return option.crashHook
+}
+
+// forCrash 崩溃文件参数: 沿用分割与保留配置, 关闭按级别分文件, 缓冲, 头尾与实时加密等不适用于 fd 直接写入的功能
+func (option *Options) forCrash() *Options {
+	var opt = *option
+	opt.LogName = option.CrashLogName
+	opt.level = ""
+	opt.SplitLevels = false
+	opt.LevelMaxAge = nil
+	opt.Mode = ModeRotate
+	opt.Shared = false
+	opt.SegmentHeader = false
+	opt.BufferSize = 0
+	opt.Fsync = FsyncNever
+	opt.RotateOnStartup = false
+	if opt.Encrypt == EncryptLive {
+		opt.Encrypt = EncryptRotated
 	}
-	var optArr []rotatelogs.Option
-	// WithLinkName为最新的日志建立软连接，以方便随着找到当前日志文件
-	optArr = append(optArr, rotatelogs.WithLinkName(option.LogName))
-	// WithRotationTime设置日志分割的时间，这里设置为一小时分割一次
-	if option.RotationTime > 0 {
-		optArr = append(optArr, rotatelogs.WithRotationTime(option.RotationTime))
+	return &opt
+}
+
+// SetKeyRing 设置加密密钥, 优先于 EncryptKey 与 EncryptKeyFile
+func (option *Options) SetKeyRing(keys *KeyRing) *Options {
+	option.keys = keys
+	return option
+}
+
+// GetKeyRing 获取加密密钥, 未配置时返回 nil
+func (option *Options) GetKeyRing() (*KeyRing, error) {
+	if option.keys != nil {
+		return option.keys, nil
 	}
-	// WithMaxAge和WithRotationCount二者只能设置一个，
-	// WithMaxAge设置文件清理前的最长保存时间，
-	// WithRotationCount设置文件清理前最多保存的个数。
-	if option.MaxAge > 0 {
-		optArr = append(optArr, rotatelogs.WithMaxAge(option.MaxAge))
+	switch {
+	case option.EncryptKey != "":
+		return ParseKeyRing(option.EncryptKey)
+	case option.EncryptKeyFile != "":
+		return LoadKeyRing(option.EncryptKeyFile)
 	}
-	if option.RotationCount > 0 && option.MaxAge <= 0 {
-		optArr = append(optArr, rotatelogs.WithRotationCount(option.RotationCount))
+	return nil, nil
+}
+
+// getEncryption 加密模式与密钥, 开启加密时必须配置密钥
+func (option *Options) getEncryption() (string, *KeyRing, error) {
+	var (
+		mode      = strings.ToLower(strings.TrimSpace(option.Encrypt))
+		keys, err = option.GetKeyRing()
+	)
+	if err != nil {
+		return "", nil, err
 	}
-	if optArr != nil && len(optArr) > 0 {
-		option.optArr = optArr
+	switch mode {
+	case "":
+		return "", keys, nil
+	case EncryptRotated, EncryptLive:
+		if keys == nil {
+			return "", nil, fmt.Errorf("encrypt mode %s requires encrypt_key or encrypt_key_file", mode)
+		}
+		return mode, keys, nil
+	}
+	return "", nil, fmt.Errorf("unknown encrypt mode %q, expect %s or %s", option.Encrypt, EncryptRotated, EncryptLive)
+}
+
+// GetLevel 解析最低日志级别, 支持别名, 数值与阈值表达式, eg: warning, 3, >=warn, warn+; 未设置时为 warn
+func (option *Options) GetLevel() (log.Level, error) {
+	if strings.TrimSpace(option.Level) == "" {
+		return log.WarnLevel, nil
 	}
-	return optArr
+	return entity.ParseLevelThreshold(option.Level)
+}
+
+// IsSplitLevels 是否按日志级别分文件写入
+func (option *Options) IsSplitLevels() bool {
+	if option.level != "" {
+		return false
+	}
+	return option.SplitLevels || len(option.LevelMaxAge) > 0 || hasPlaceholder(option.LogNameLayout, PlaceholderLevel)
+}
+
+// GetLevelMaxAge 解析按级别保留时长
+func (option *Options) GetLevelMaxAge() (map[log.Level]time.Duration, error) {
+	var ages = make(map[log.Level]time.Duration)
+	for name, value := range option.LevelMaxAge {
+		var level, err = entity.ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("level_max_age: %v", err)
+		}
+		age, err := ParseAge(value)
+		if err != nil {
+			return nil, err
+		}
+		ages[level] = age
+	}
+	return ages, nil
+}
+
+// ForLevel 构建指定级别的分文件参数, eg: app.log => app-error.log,
+// 布局含 {level} 占位符时由布局决定文件名
+func (option *Options) ForLevel(level log.Level, maxAge ...time.Duration) *Options {
+	var (
+		opt  = *option
+		name = strings.TrimSuffix(option.LogName, LogExt)
+	)
+	opt.level = level.String()
+	if !hasPlaceholder(option.LogNameLayout, PlaceholderLevel) {
+		opt.LogName = name + "-" + level.String()
+		if strings.HasSuffix(option.LogName, LogExt) {
+			opt.LogName = opt.LogName + LogExt
+		}
+	}
+	opt.LevelMaxAge = nil
+	opt.SplitLevels = false
+	if len(maxAge) > 0 && maxAge[0] > 0 {
+		opt.MaxAge = maxAge[0]
+	}
+	return &opt
+}
+
+// GetMode 写入模式, 默认 rotate
+func (option *Options) GetMode() string {
+	switch strings.ToLower(strings.TrimSpace(option.Mode)) {
+	case ModeReopen:
+		return ModeReopen
+	}
+	return ModeRotate
+}
+
+// GetFileName reopen 模式下的固定日志文件, eg: app => app.log
+func (option *Options) GetFileName() string {
+	var name = option.LogName
+	if name != "" && filepath.Ext(name) == "" {
+		name = name + LogExt
+	}
+	return name
+}
+
+// GetLayout 替换占位符后的分段文件 strftime 布局, 含未知占位符时返回错误
+func (option *Options) GetLayout() (string, error) {
+	return expandPlaceholders(option.getSessionLayout(), option.level, false)
+}
+
+// GetGlobPattern 匹配分段文件的 glob 表达式 (pid, session 及未指定的级别匹配任意值)
+func (option *Options) GetGlobPattern() (string, error) {
+	var layout, err = expandPlaceholders(option.getSessionLayout(), option.level, true)
+	if err != nil {
+		return "", err
+	}
+	return patternConversion.ReplaceAllString(layout, "*"), nil
+}
+
+// getSessionLayout session 命名模式下在扩展名前追加 {session}
+func (option *Options) getSessionLayout() string {
+	var layout = option.GetLinkName()
+	if !option.SessionNaming || hasPlaceholder(layout, PlaceholderSession) {
+		return layout
+	}
+	var ext = filepath.Ext(layout)
+	return strings.TrimSuffix(layout, ext) + "-{" + PlaceholderSession + "}" + ext
+}
+
+// GetLinkFile 指向最新分段的软链接, 按 {level} 分文件时追加级别, eg: app-error.log
+func (option *Options) GetLinkFile() string {
+	if option.level == "" || !hasPlaceholder(option.LogNameLayout, PlaceholderLevel) {
+		return option.LogName
+	}
+	var ext = filepath.Ext(option.LogName)
+	return strings.TrimSuffix(option.LogName, ext) + "-" + option.level + ext
 }
 
 func (option *Options) GetLinkName() string {
@@ -189,7 +446,7 @@ func CreateDefaultOptions() *Options {
 	opt.LogName = "app.log"
 	opt.MaxAge = 0
 	opt.Level = "warn"
-	opt.DisableColors = false
+	opt.DisableColors = true
 	opt.RotationCount = 20
 	opt.LogNameLayout = `%s-%Y%m%d.log`
 	opt.RotationTime = 24 * time.Hour
@@ -201,14 +458,22 @@ func CreateOptionsWithLogName(name string) *Options {
 	opt.MaxAge = 0
 	opt.LogName = name
 	opt.Level = "warn"
-	opt.DisableColors = false
+	opt.DisableColors = true
 	opt.RotationCount = 20
 	opt.LogNameLayout = `%s-%Y%m%d` + LogExt
 	opt.RotationTime = 24 * time.Hour
 	return opt
 }
 
+// CreateOptionsWithEnv 从环境变量构建 Options, prefix[0] 为前缀, prefix[1] 为后缀; 忽略解析/校验错误, 错误见 CreateOptionsWithEnvE
 func CreateOptionsWithEnv(caseMode utils.CaseMode, prefix ...string) *Options {
+	var opt, _ = CreateOptionsWithEnvE(caseMode, prefix...)
+	return opt
+}
+
+// CreateOptionsWithEnvE 从环境变量构建 Options, prefix[0] 为前缀, prefix[1] 为后缀;
+// 解析/校验失败的字段返回 utils.EnvErrors, 其余字段照常加载
+func CreateOptionsWithEnvE(caseMode utils.CaseMode, prefix ...string) (*Options, error) {
 	var (
 		argc    = len(prefix)
 		opt     = new(Options)
@@ -220,8 +485,5 @@ func CreateOptionsWithEnv(caseMode utils.CaseMode, prefix ...string) *Options {
 	if argc >= 2 && prefix[1] != "" {
 		decoder.SetSuffix(prefix[1])
 	}
-	if err := decoder.Marshal(opt); err != nil {
-		log.Error("decoder option error:", err)
-	}
-	return opt
+	return opt, decoder.Marshal(opt)
 }
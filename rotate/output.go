@@ -0,0 +1,106 @@
+package rotate
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// outputWriter logger.SetOutput 使用的分割写入器, Close 同时关闭崩溃捕获
+	outputWriter struct {
+		io.WriteCloser
+		crash *CrashCapture
+	}
+
+	// exitRegistry 需在 Fatal 退出前刷新的写入器, 进程内只注册一次 logrus 退出处理
+	exitRegistry struct {
+		locker     sync.Mutex
+		writers    map[flusher]struct{}
+		registered bool
+	}
+)
+
+var (
+	exitFlushers = &exitRegistry{writers: make(map[flusher]struct{})}
+)
+
+// NewWriter 按参数构建分割写入器, 用于 logger.SetOutput 单一输出场景, 日志只格式化一次;
+// 按级别分文件与磁盘保护依赖日志级别, 需使用 hook
+func NewWriter(options *Options) (io.WriteCloser, error) {
+	if options == nil {
+		return nil, errors.New("rotate writer options missing")
+	}
+	if options.IsSplitLevels() {
+		return nil, errors.New("split levels requires the rotate hook, writer receives formatted entries without level")
+	}
+	var writer, err = newModeWriter(options)
+	if err != nil {
+		return nil, err
+	}
+	var output = &outputWriter{WriteCloser: writer}
+	if options.CrashLogName != "" {
+		if output.crash, err = CaptureCrash(options); err != nil {
+			_ = writer.Close()
+			return nil, err
+		}
+	}
+	// Fatal 写入后进程退出, 退出前刷新缓冲
+	if buffered, ok := writer.(flusher); ok {
+		exitFlushers.add(buffered)
+	}
+	return output, nil
+}
+
+// Flush 刷新写缓冲
+func (writer *outputWriter) Flush() error {
+	if buffered, ok := writer.WriteCloser.(flusher); ok {
+		return buffered.Flush()
+	}
+	return nil
+}
+
+func (writer *outputWriter) Close() error {
+	var err error
+	if buffered, ok := writer.WriteCloser.(flusher); ok {
+		exitFlushers.remove(buffered)
+	}
+	if writer.crash != nil {
+		err = writer.crash.Close()
+	}
+	if e := writer.WriteCloser.Close(); e != nil {
+		err = e
+	}
+	return err
+}
+
+func (registry *exitRegistry) add(writer flusher) {
+	registry.locker.Lock()
+	defer registry.locker.Unlock()
+	registry.writers[writer] = struct{}{}
+	if registry.registered {
+		return
+	}
+	registry.registered = true
+	log.RegisterExitHandler(registry.flush)
+}
+
+func (registry *exitRegistry) remove(writer flusher) {
+	registry.locker.Lock()
+	defer registry.locker.Unlock()
+	delete(registry.writers, writer)
+}
+
+func (registry *exitRegistry) flush() {
+	registry.locker.Lock()
+	var writers = make([]flusher, 0, len(registry.writers))
+	for v := range registry.writers {
+		writers = append(writers, v)
+	}
+	registry.locker.Unlock()
+	for _, v := range writers {
+		_ = v.Flush()
+	}
+}
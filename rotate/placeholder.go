@@ -0,0 +1,92 @@
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 文件名占位符, eg: app-{hostname}-{level}-%Y%m%d.log, app-{env:POD_NAME}-%Y%m%d.log, app-%Y%m%d-{session}.log
+const (
+	PlaceholderHostname = "hostname"
+	PlaceholderPid      = "pid"
+	PlaceholderProcess  = "process"
+	PlaceholderEnv      = "env"
+	PlaceholderLevel    = "level"
+	PlaceholderSession  = "session"
+
+	sessionLayout = "20060102T150405"
+)
+
+var (
+	// processStartAt 进程启动时间, 用于 {session} 占位符
+	processStartAt    = time.Now()
+	placeholderRegexp = regexp.MustCompile(`\{([^{}:]*)(?::([^{}]*))?\}`)
+	unsafeNameChars   = strings.NewReplacer("/", "-", `\`, "-", " ", "-", "%", "%%")
+)
+
+// expandPlaceholders 替换文件名占位符, glob 为 true 时 pid, session 与未指定的 level 替换为通配符
+func expandPlaceholders(layout string, level string, glob bool) (string, error) {
+	var expandErr error
+	var result = placeholderRegexp.ReplaceAllStringFunc(layout, func(match string) string {
+		var (
+			parts = placeholderRegexp.FindStringSubmatch(match)
+			name  = strings.ToLower(strings.TrimSpace(parts[1]))
+			arg   = strings.TrimSpace(parts[2])
+			value string
+		)
+		switch name {
+		case PlaceholderHostname:
+			value, _ = os.Hostname()
+		case PlaceholderPid:
+			if glob {
+				return "*"
+			}
+			value = strconv.Itoa(os.Getpid())
+		case PlaceholderSession:
+			if glob {
+				return "*"
+			}
+			value = processStartAt.Format(sessionLayout)
+		case PlaceholderProcess:
+			value = strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+		case PlaceholderEnv:
+			if arg == "" {
+				expandErr = fmt.Errorf("placeholder %s requires a variable name, eg: {env:POD_NAME}", match)
+				return match
+			}
+			value = os.Getenv(arg)
+		case PlaceholderLevel:
+			if level == "" {
+				if glob {
+					return "*"
+				}
+				expandErr = fmt.Errorf("placeholder %s requires split levels", match)
+				return match
+			}
+			value = level
+		default:
+			expandErr = fmt.Errorf("unknown placeholder %s in layout %q", match, layout)
+			return match
+		}
+		if value == "" {
+			value = "unknown"
+		}
+		return unsafeNameChars.Replace(value)
+	})
+	return result, expandErr
+}
+
+// hasPlaceholder 布局中是否包含指定占位符
+func hasPlaceholder(layout string, name string) bool {
+	for _, v := range placeholderRegexp.FindAllStringSubmatch(layout, -1) {
+		if strings.ToLower(strings.TrimSpace(v[1])) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package rotate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	var hostname, _ = os.Hostname()
+	_ = os.Setenv("ROTATE_TEST_POD", "pod/1")
+	defer os.Unsetenv("ROTATE_TEST_POD")
+	var cases = []struct {
+		layout, level string
+		glob          bool
+		expect        string
+	}{
+		{layout: "app-{hostname}-%Y.log", expect: "app-" + hostname + "-%Y.log"},
+		{layout: "app-{pid}.log", expect: "app-" + strconv.Itoa(os.Getpid()) + ".log"},
+		{layout: "app-{pid}.log", glob: true, expect: "app-*.log"},
+		{layout: "app-{env:ROTATE_TEST_POD}.log", expect: "app-pod-1.log"},
+		{layout: "app-{level}-%Y.log", level: "error", expect: "app-error-%Y.log"},
+		{layout: "app-{level}-%Y.log", glob: true, expect: "app-*-%Y.log"},
+	}
+	for _, v := range cases {
+		var result, err = expandPlaceholders(v.layout, v.level, v.glob)
+		if err != nil || result != v.expect {
+			t.Errorf("%s: expect %q, got %q (%v)", v.layout, v.expect, result, err)
+		}
+	}
+	for _, layout := range []string{"app-{host}.log", "app-{env}.log", "app-{level}.log"} {
+		if _, err := expandPlaceholders(layout, "", false); err == nil {
+			t.Errorf("%s: expect error", layout)
+		}
+	}
+}
+
+func TestRotateFactory_LevelPlaceholder(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		options = newTestOptions(dir, &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)})
+		logger  = log.New()
+	)
+	options.LogNameLayout = "%s-{process}-{level}-%Y%m%d.log"
+	hook, err := CreateRotateFactory().Create(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(hook)
+	logger.Error("failed")
+	_ = hook.(*rotateHook).Close()
+	var process = strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+	if _, err = os.Stat(filepath.Join(dir, "app-"+process+"-error-20210101.log")); err != nil {
+		t.Errorf("level segment missing: %v", err)
+	}
+	if _, err = os.Lstat(filepath.Join(dir, "app-error.log")); err != nil {
+		t.Errorf("level link missing: %v", err)
+	}
+	options.LogNameLayout = "%s-{unknown}-%Y%m%d.log"
+	if _, err = CreateRotateFactory().Create(options); err == nil {
+		t.Error("expect unknown placeholder error")
+	}
+}
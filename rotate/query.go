@@ -0,0 +1,302 @@
+package rotate
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/weblfe/logrus_hooks/entity"
+)
+
+type (
+	// Query 日志检索条件, 零值表示不限
+	Query struct {
+		From time.Time
+		To   time.Time
+		// Level 最低级别, eg: error 匹配 error/fatal/panic
+		Level string
+		// Fields 字段精确匹配
+		Fields map[string]string
+	}
+
+	// Record 检索到的日志
+	Record struct {
+		Time    time.Time
+		Level   log.Level
+		Message string
+		Fields  map[string]string
+		Segment string
+		Line    string
+	}
+
+	// querySegment 待检索分段
+	querySegment struct {
+		path    string
+		startAt time.Time
+	}
+)
+
+// QueryLogs 按时间范围/级别/字段检索日志目录下的分段 (含 gzip 与加密分段), fn 返回 false 时停止
+func QueryLogs(options *Options, query Query, fn func(record *Record) bool) error {
+	if options == nil || fn == nil {
+		return fmt.Errorf("query options or callback missing")
+	}
+	var minLevel = log.TraceLevel
+	if query.Level != "" {
+		var level, err = entity.ParseLevelThreshold(query.Level)
+		if err != nil {
+			return fmt.Errorf("query level: %v", err)
+		}
+		minLevel = level
+	}
+	var _, keys, err = options.getEncryption()
+	if err != nil {
+		return err
+	}
+	segments, err := query.segments(options)
+	if err != nil {
+		return err
+	}
+	for _, v := range segments {
+		var next, err = query.scan(v.path, keys, minLevel, fn)
+		if err != nil {
+			return err
+		}
+		if !next {
+			return nil
+		}
+	}
+	return nil
+}
+
+// segments 按清单时间范围筛选分段, 未登记的分段全部检索
+func (query Query) segments(options *Options) ([]querySegment, error) {
+	var pattern, err = options.GetGlobPattern()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := LoadManifest(filepath.Dir(pattern))
+	if err != nil {
+		return nil, err
+	}
+	matches, err := globSegments(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var segments []querySegment
+	for _, v := range matches {
+		var info, err = os.Lstat(v)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		var segment = querySegment{path: v, startAt: info.ModTime()}
+		if meta := manifest.Get(v); meta != nil {
+			if !query.overlaps(meta) {
+				continue
+			}
+			segment.startAt = meta.FirstAt
+		}
+		segments = append(segments, segment)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].startAt.Before(segments[j].startAt)
+	})
+	return segments, nil
+}
+
+func (query Query) overlaps(meta *SegmentMeta) bool {
+	if meta.FirstAt.IsZero() {
+		return true
+	}
+	if !query.To.IsZero() && meta.FirstAt.After(query.To) {
+		return false
+	}
+	// 未关闭分段末次写入时间不准确
+	if !query.From.IsZero() && meta.Closed && meta.LastAt.Before(query.From) {
+		return false
+	}
+	return true
+}
+
+func (query Query) scan(name string, keys *KeyRing, minLevel log.Level, fn func(record *Record) bool) (bool, error) {
+	var reader, err = OpenSegment(name, keys)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	defer reader.Close()
+	var buf = bufio.NewReader(reader)
+	for {
+		var line, err = buf.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" && !IsSegmentMark(line) {
+			var record = ParseRecord(line)
+			record.Segment = name
+			if query.match(record, minLevel) && !fn(record) {
+				return false, nil
+			}
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("read %s: %v", name, err)
+		}
+	}
+}
+
+func (query Query) match(record *Record, minLevel log.Level) bool {
+	if record.Level > minLevel {
+		return false
+	}
+	if !query.From.IsZero() && (record.Time.IsZero() || record.Time.Before(query.From)) {
+		return false
+	}
+	if !query.To.IsZero() && (record.Time.IsZero() || record.Time.After(query.To)) {
+		return false
+	}
+	for k, v := range query.Fields {
+		if value, ok := record.Fields[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	// ansiPattern 终端颜色转义序列
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// colorLinePattern logrus 着色输出: LEVL[时间或秒数] 消息 字段...
+	colorLinePattern = regexp.MustCompile(`^\x1b\[[0-9;]*m([A-Z]+)\x1b\[0m\[([^\]]*)\] ?(.*)$`)
+)
+
+// ParseRecord 解析一行日志 (logrus Text, 着色 Text 或 JSON 格式)
+func ParseRecord(line string) *Record {
+	var (
+		record = &Record{Line: line, Level: log.InfoLevel, Fields: make(map[string]string)}
+		values map[string]string
+	)
+	switch {
+	case strings.HasPrefix(line, "{"):
+		values = parseJsonLine(line)
+	case strings.HasPrefix(line, "\x1b["):
+		values = parseColorLine(line)
+	default:
+		values = parseTextLine(ansiPattern.ReplaceAllString(line, ""))
+	}
+	for k, v := range values {
+		switch k {
+		case log.FieldKeyTime:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				record.Time = t
+			}
+		case log.FieldKeyLevel:
+			if level, err := log.ParseLevel(v); err == nil {
+				record.Level = level
+			}
+		case log.FieldKeyMsg:
+			record.Message = v
+		default:
+			record.Fields[k] = v
+		}
+	}
+	return record
+}
+
+// parseColorLine 解析终端着色格式, 级别为 4 位缩写 (WARN, ERRO ...), 字段名着色, 秒数形式的时间忽略
+func parseColorLine(line string) map[string]string {
+	var match = colorLinePattern.FindStringSubmatch(line)
+	if match == nil {
+		return parseTextLine(ansiPattern.ReplaceAllString(line, ""))
+	}
+	var (
+		rest   = match[3]
+		values = make(map[string]string)
+	)
+	if i := strings.Index(rest, "\x1b["); i >= 0 {
+		values = parseTextLine(ansiPattern.ReplaceAllString(rest[i:], ""))
+		rest = rest[:i]
+	}
+	values[log.FieldKeyMsg] = strings.TrimSpace(rest)
+	for _, level := range log.AllLevels {
+		if text := strings.ToUpper(level.String()); len(text) >= 4 && text[:4] == match[1] {
+			values[log.FieldKeyLevel] = level.String()
+			break
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, match[2]); err == nil {
+		values[log.FieldKeyTime] = match[2]
+	}
+	return values
+}
+
+func parseJsonLine(line string) map[string]string {
+	var (
+		data   map[string]interface{}
+		values = make(map[string]string)
+	)
+	if err := json.Unmarshal([]byte(line), &data); err != nil {
+		return values
+	}
+	for k, v := range data {
+		if str, ok := v.(string); ok {
+			values[k] = str
+			continue
+		}
+		var bytes, _ = json.Marshal(v)
+		values[k] = string(bytes)
+	}
+	return values
+}
+
+// parseTextLine 解析 key=value 形式, 值可为带引号的转义字符串
+func parseTextLine(line string) map[string]string {
+	var values = make(map[string]string)
+	for i := 0; i < len(line); {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		var eq = strings.IndexByte(line[i:], '=')
+		if eq <= 0 {
+			break
+		}
+		var key = line[i : i+eq]
+		i += eq + 1
+		if i < len(line) && line[i] == '"' {
+			var end = i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				end = len(line) - 1
+			}
+			var value, err = strconv.Unquote(line[i : end+1])
+			if err != nil {
+				value = strings.Trim(line[i:end+1], `"`)
+			}
+			values[key] = value
+			i = end + 1
+			continue
+		}
+		var end = strings.IndexByte(line[i:], ' ')
+		if end < 0 {
+			end = len(line) - i
+		}
+		values[key] = line[i : i+end]
+		i += end
+	}
+	return values
+}
@@ -0,0 +1,109 @@
+package rotate
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestQueryLogs(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		start   = time.Date(2021, 1, 1, 1, 50, 0, 0, time.UTC)
+		clock   = &fakeClock{now: start}
+		options = newTestOptions(dir, clock)
+		logger  = log.New()
+	)
+	options.RotationTime = time.Hour
+	options.LogNameLayout = "%s-%Y%m%d%H.log"
+	options.Compress = true
+	options.Level = "debug"
+	options.Manifest = true
+	hook, err := CreateRotateFactory().Create(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.SetOutput(ioutil.Discard)
+	logger.SetLevel(log.DebugLevel)
+	logger.AddHook(hook)
+	for i := 0; i < 6; i++ {
+		var entry = logger.WithTime(clock.Now()).WithField("job", "sync")
+		entry.Infof("tick %d", i)
+		if i%2 == 0 {
+			entry.WithField("code", i).Errorf("failed %d", i)
+		}
+		clock.Add(10 * time.Minute)
+	}
+	var writer = hook.(interface{ Close() error })
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := LoadManifest(dir)
+	if err != nil || len(manifest.Segments) != 2 || !manifest.Segments[0].Compressed || manifest.Segments[0].Lines != 2 {
+		t.Fatalf("unexpected manifest %+v: %v", manifest, err)
+	}
+	var (
+		records []*Record
+		query   = Query{
+			From:   time.Date(2021, 1, 1, 2, 0, 0, 0, time.UTC),
+			To:     time.Date(2021, 1, 1, 2, 15, 0, 0, time.UTC),
+			Level:  "error",
+			Fields: map[string]string{"job": "sync"},
+		}
+	)
+	err = QueryLogs(options, query, func(record *Record) bool {
+		records = append(records, record)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Message != "failed 2" || records[0].Fields["code"] != "2" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	if filepath.Base(records[0].Segment) != "app-2021010102.log" {
+		t.Errorf("unexpected segment %s", records[0].Segment)
+	}
+	query = Query{Level: "info"}
+	records = nil
+	_ = QueryLogs(options, query, func(record *Record) bool {
+		records = append(records, record)
+		return true
+	})
+	if len(records) != 9 {
+		t.Errorf("expect 9 records across plain and gzip segments, got %d", len(records))
+	}
+}
+
+func TestParseRecord_Colored(t *testing.T) {
+	var (
+		at     = time.Date(2021, 1, 1, 2, 0, 0, 0, time.UTC)
+		logger = log.New()
+		entry  = log.NewEntry(logger).WithTime(at).WithField("job", "sync")
+	)
+	entry.Level, entry.Message = log.WarnLevel, "disk low"
+	for _, formatter := range []*log.TextFormatter{
+		{ForceColors: true, FullTimestamp: true},
+		{ForceColors: true},
+	} {
+		var data, err = formatter.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var record = ParseRecord(strings.TrimSuffix(string(data), "\n"))
+		if record.Level != log.WarnLevel || record.Message != "disk low" || record.Fields["job"] != "sync" {
+			t.Errorf("unexpected record %+v from %q", record, data)
+		}
+		if formatter.FullTimestamp && !record.Time.Equal(at) {
+			t.Errorf("unexpected time %v from %q", record.Time, data)
+		}
+	}
+	var record = ParseRecord("time=\"2021-01-01T02:00:00Z\" level=error msg=failed \x1b[31mcode\x1b[0m=2")
+	if record.Level != log.ErrorLevel || record.Fields["code"] != "2" {
+		t.Errorf("unexpected stripped record %+v", record)
+	}
+}
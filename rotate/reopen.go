@@ -0,0 +1,200 @@
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type (
+	// reopenWriter 固定路径追加写入, 配合外部 logrotate 使用 (信号/手动/检测到移动或截断时重新打开)
+	reopenWriter struct {
+		locker    sync.Mutex
+		path      string
+		file      *os.File
+		info      os.FileInfo
+		size      int64
+		interval  time.Duration
+		clock     Clock
+		checkedAt time.Time
+	}
+
+	// reopenRegistry 已注册的 reopen 写入器, 统一响应信号; 全部写入器关闭后停止监听
+	reopenRegistry struct {
+		locker  sync.Mutex
+		writers map[*reopenWriter]struct{}
+		signals chan os.Signal
+	}
+)
+
+const (
+	ModeRotate = "rotate"
+	ModeReopen = "reopen"
+
+	defaultReopenCheckInterval = time.Second
+)
+
+var (
+	reopeners = &reopenRegistry{writers: make(map[*reopenWriter]struct{})}
+)
+
+// Reopen 重新打开全部 reopen 模式的日志文件
+func Reopen() error {
+	return reopeners.reopen()
+}
+
+func newReopenWriter(options *Options) (*reopenWriter, error) {
+	if options == nil {
+		return nil, fmt.Errorf("reopen writer options missing")
+	}
+	if options.Encrypt != "" {
+		return nil, fmt.Errorf("encryption is not supported in %s mode", ModeReopen)
+	}
+	var writer = new(reopenWriter)
+	writer.path = options.GetFileName()
+	writer.clock = options.GetClock()
+	writer.interval = options.ReopenCheckInterval
+	if writer.interval <= 0 {
+		writer.interval = defaultReopenCheckInterval
+	}
+	if writer.path == "" {
+		return nil, fmt.Errorf("reopen writer log name missing")
+	}
+	reopeners.add(writer)
+	return writer, nil
+}
+
+// Write 追加写入, 定期检测文件是否被移动或截断
+func (writer *reopenWriter) Write(p []byte) (int, error) {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	if writer.file == nil {
+		if err := writer.open(); err != nil {
+			return 0, err
+		}
+	} else if now := writer.clock.Now(); now.Sub(writer.checkedAt) >= writer.interval {
+		writer.checkedAt = now
+		if err := writer.check(); err != nil {
+			return 0, err
+		}
+	}
+	var n, err = writer.file.Write(p)
+	writer.size += int64(n)
+	return n, err
+}
+
+// Reopen 关闭并重新打开日志文件
+func (writer *reopenWriter) Reopen() error {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	return writer.open()
+}
+
+// Close 关闭文件并停止响应信号
+func (writer *reopenWriter) Close() error {
+	reopeners.remove(writer)
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	if writer.file == nil {
+		return nil
+	}
+	var err = writer.file.Close()
+	writer.file = nil
+	return err
+}
+
+// check 文件被移动 (inode 变化) 时重新打开, 被截断时重置偏移
+func (writer *reopenWriter) check() error {
+	var info, err = os.Stat(writer.path)
+	if err != nil || !os.SameFile(info, writer.info) {
+		return writer.open()
+	}
+	if info.Size() < writer.size {
+		writer.report(fmt.Errorf("%s truncated (%d -> %d bytes)", writer.path, writer.size, info.Size()))
+	}
+	writer.size = info.Size()
+	return nil
+}
+
+func (writer *reopenWriter) open() error {
+	var dir = filepath.Dir(writer.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+	var file, err = os.OpenFile(writer.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %v", writer.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	if writer.file != nil {
+		if err := writer.file.Close(); err != nil {
+			writer.report(err)
+		}
+	}
+	writer.file = file
+	writer.info = info
+	writer.size = info.Size()
+	writer.checkedAt = writer.clock.Now()
+	return nil
+}
+
+func (writer *reopenWriter) report(err error) {
+	if err == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "rotate: %v\n", err)
+}
+
+func (registry *reopenRegistry) add(writer *reopenWriter) {
+	registry.locker.Lock()
+	defer registry.locker.Unlock()
+	registry.writers[writer] = struct{}{}
+	if registry.signals != nil || len(reopenSignals) <= 0 {
+		return
+	}
+	var ch = make(chan os.Signal, 1)
+	registry.signals = ch
+	signal.Notify(ch, reopenSignals...)
+	go func() {
+		for range ch {
+			if err := registry.reopen(); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "rotate: %v\n", err)
+			}
+		}
+	}()
+}
+
+func (registry *reopenRegistry) remove(writer *reopenWriter) {
+	registry.locker.Lock()
+	defer registry.locker.Unlock()
+	delete(registry.writers, writer)
+	if len(registry.writers) > 0 || registry.signals == nil {
+		return
+	}
+	signal.Stop(registry.signals)
+	close(registry.signals)
+	registry.signals = nil
+}
+
+func (registry *reopenRegistry) reopen() error {
+	registry.locker.Lock()
+	var writers = make([]*reopenWriter, 0, len(registry.writers))
+	for v := range registry.writers {
+		writers = append(writers, v)
+	}
+	registry.locker.Unlock()
+	var last error
+	for _, v := range writers {
+		if err := v.Reopen(); err != nil {
+			last = err
+		}
+	}
+	return last
+}
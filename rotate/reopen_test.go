@@ -0,0 +1,104 @@
+package rotate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReopenWriter(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+		file    = filepath.Join(dir, "app.log")
+	)
+	options.Mode = ModeReopen
+	writer, err := newModeWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	var write = func(line string) {
+		clock.Add(2 * time.Second)
+		if _, err := writer.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var expect = func(name, content string) {
+		if data, err := os.ReadFile(name); err != nil || string(data) != content {
+			t.Errorf("%s: expect %q, got %q (%v)", name, content, data, err)
+		}
+	}
+	write("first\n")
+	// logrotate create: 文件被移动后自动重新打开
+	if err = os.Rename(file, file+".1"); err != nil {
+		t.Fatal(err)
+	}
+	write("second\n")
+	expect(file+".1", "first\n")
+	expect(file, "second\n")
+	// logrotate copytruncate: 截断后继续追加
+	if err = os.Truncate(file, 0); err != nil {
+		t.Fatal(err)
+	}
+	write("third\n")
+	expect(file, "third\n")
+	// 手动 Reopen
+	if err = os.Rename(file, file+".2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write([]byte("fourth\n")); err != nil {
+		t.Fatal(err)
+	}
+	expect(file, "fourth\n")
+}
+
+func TestNewLfsHook_ClosesWritersOnError(t *testing.T) {
+	var options = newTestOptions(t.TempDir(), &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)})
+	options.Mode = ModeReopen
+	options.SplitLevels = true
+	options.DiskSoftFree = "lots"
+	var count = func() int {
+		reopeners.locker.Lock()
+		defer reopeners.locker.Unlock()
+		return len(reopeners.writers)
+	}
+	var before = count()
+	if _, err := CreateRotateFactory().Create(options); err == nil {
+		t.Fatal("expect disk guard error")
+	}
+	if after := count(); after != before {
+		t.Errorf("writers leaked: %d registered before, %d after", before, after)
+	}
+}
+
+func TestReopenRegistry_StopSignals(t *testing.T) {
+	if len(reopenSignals) == 0 {
+		t.Skip("reopen signals unsupported")
+	}
+	var options = newTestOptions(t.TempDir(), nil)
+	options.Mode = ModeReopen
+	var listening = func() bool {
+		reopeners.locker.Lock()
+		defer reopeners.locker.Unlock()
+		return reopeners.signals != nil
+	}
+	for i := 0; i < 2; i++ {
+		writer, err := newModeWriter(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !listening() {
+			t.Fatalf("round %d: expect signal listening with open writer", i)
+		}
+		_ = writer.Close()
+		if listening() {
+			t.Errorf("round %d: signal listening after last writer closed", i)
+		}
+	}
+}
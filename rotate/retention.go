@@ -0,0 +1,141 @@
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// RemoveEvent 历史分段删除事件
+	RemoveEvent struct {
+		Path    string
+		Size    int64
+		ModTime time.Time
+		Reason  string
+	}
+
+	// RemoveHandler 分段删除回调 (审计)
+	RemoveHandler func(event RemoveEvent)
+
+	// activeRegistry 各写入器当前打开的分段, 清理时跳过
+	activeRegistry struct {
+		locker sync.RWMutex
+		files  map[string]int
+	}
+)
+
+const (
+	RemoveByMaxAge        = "max_age"
+	RemoveByRotationCount = "rotation_count"
+	RemoveByMaxTotalSize  = "max_total_size"
+)
+
+var (
+	activeSegments = &activeRegistry{files: make(map[string]int)}
+)
+
+func (registry *activeRegistry) add(name string) {
+	if name == "" {
+		return
+	}
+	registry.locker.Lock()
+	defer registry.locker.Unlock()
+	registry.files[filepath.Clean(name)]++
+}
+
+func (registry *activeRegistry) remove(name string) {
+	if name == "" {
+		return
+	}
+	registry.locker.Lock()
+	defer registry.locker.Unlock()
+	var key = filepath.Clean(name)
+	if registry.files[key] <= 1 {
+		delete(registry.files, key)
+		return
+	}
+	registry.files[key]--
+}
+
+func (registry *activeRegistry) has(name string) bool {
+	registry.locker.RLock()
+	defer registry.locker.RUnlock()
+	return registry.files[filepath.Clean(name)] > 0
+}
+
+// enforceTotalSize 目录日志总大小超限时从最旧的分段开始删除
+func (writer *rotateWriter) enforceTotalSize(removed map[string]bool) {
+	if writer.maxTotalSize <= 0 || writer.totalPattern == "" {
+		return
+	}
+	var (
+		total    int64
+		segments = writer.match(writer.totalPattern, "")
+		history  []segmentInfo
+	)
+	for _, v := range segments {
+		if removed[v.path] {
+			continue
+		}
+		total += v.size
+		if !activeSegments.has(v.path) {
+			history = append(history, v)
+		}
+	}
+	for _, v := range history {
+		if total <= writer.maxTotalSize {
+			return
+		}
+		if writer.remove(v, RemoveByMaxTotalSize) {
+			total -= v.size
+		}
+	}
+}
+
+// remove 删除历史分段并回调审计, 未归档成功的分段不删除
+func (writer *rotateWriter) remove(segment segmentInfo, reason string) bool {
+	if writer.pending[segment.path] {
+		return false
+	}
+	if err := os.Remove(segment.path); err != nil {
+		if !os.IsNotExist(err) {
+			writer.report(err)
+		}
+		return false
+	}
+	writer.manifestUpdate(segment.path, func(manifest *Manifest, meta *SegmentMeta) {
+		manifest.remove(segment.path)
+	})
+	var event = RemoveEvent{Path: segment.path, Size: segment.size, ModTime: segment.modTime, Reason: reason}
+	if writer.onRemove != nil {
+		writer.onRemove(event)
+		return true
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "rotate: removed %s (%s, %d bytes)\n", event.Path, event.Reason, event.Size)
+	return true
+}
+
+// ParseAge 解析保留时长, 支持 time.ParseDuration 格式及天数 eg: 90d, 36h
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(value, "d") {
+		var days, err = strconv.ParseFloat(strings.TrimSuffix(value, "d"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q: %v", value, err)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	var age, err = time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q: %v", value, err)
+	}
+	return age, nil
+}
@@ -0,0 +1,95 @@
+package rotate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestRotateWriter_MaxTotalSize(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+		events  []RemoveEvent
+		line    = []byte(strings.Repeat("x", 99) + "\n")
+	)
+	options.RotationCount = 0
+	options.MaxAge = 365 * 24 * time.Hour
+	options.MaxTotalSize = 250
+	options.SetRemoveHandler(func(event RemoveEvent) {
+		events = append(events, event)
+	})
+	writer, err := newRotateWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err = writer.Write(line); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(writer.CurrentFileName(), clock.Now(), clock.Now())
+		clock.Add(24 * time.Hour)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expect 2 removals, got %v", events)
+	}
+	for i, name := range []string{"app-20210101.log", "app-20210102.log"} {
+		if filepath.Base(events[i].Path) != name || events[i].Reason != RemoveByMaxTotalSize {
+			t.Errorf("unexpected removal %+v", events[i])
+		}
+	}
+}
+
+func TestNewWriterMap_LevelMaxAge(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		now     = time.Date(2021, 1, 10, 10, 0, 0, 0, time.UTC)
+		options = newTestOptions(dir, &fakeClock{now: now})
+		old     = now.Add(-5 * 24 * time.Hour)
+		locker  sync.Mutex
+		removed []string
+	)
+	options.LevelMaxAge = map[string]string{"error": "90d", "debug": "3d"}
+	options.SetRemoveHandler(func(event RemoveEvent) {
+		locker.Lock()
+		defer locker.Unlock()
+		removed = append(removed, filepath.Base(event.Path))
+	})
+	for _, name := range []string{"app-error-20210105.log", "app-debug-20210105.log"} {
+		var file = filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(file, old, old)
+	}
+	writerMap, err := newWriterMap(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		closeWriters(writerMap)
+	})
+	for _, level := range []log.Level{log.ErrorLevel, log.DebugLevel} {
+		var writer = writerMap[level].(*rotateWriter)
+		if _, err = writer.Write([]byte("new\n")); err != nil {
+			t.Fatal(err)
+		}
+		_ = writer.Close()
+	}
+	if len(removed) != 1 || removed[0] != "app-debug-20210105.log" {
+		t.Errorf("expect only expired debug segment removed, got %v", removed)
+	}
+	options.LevelMaxAge = map[string]string{"verbose": "1d"}
+	if _, err = newWriterMap(options); err == nil {
+		t.Error("expect unknown level error")
+	}
+}
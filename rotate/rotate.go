@@ -1,11 +1,11 @@
 package rotate
 
 import (
-		"github.com/lestrrat-go/file-rotatelogs"
-		"github.com/rifflock/lfshook"
-		log "github.com/sirupsen/logrus"
-		"github.com/weblfe/logrus_hooks/entity"
-		"github.com/weblfe/logrus_hooks/utils"
+	"io"
+
+	"github.com/rifflock/lfshook"
+	log "github.com/sirupsen/logrus"
+	"github.com/weblfe/logrus_hooks/utils"
 )
 
 type (
@@ -14,6 +14,15 @@ type (
 		defaultOption *Options
 		name          string
 	}
+
+	// rotateHook 日志分割 hook, Close 关闭全部写入器
+	rotateHook struct {
+		log.Hook
+		guard     *diskGuard
+		writers   []io.WriteCloser
+		writerMap lfshook.WriterMap
+		crash     *CrashCapture
+	}
 )
 
 const (
@@ -27,21 +36,28 @@ func CreateRotateFactory() *rotateHookFactory {
 	return factory
 }
 
-
 // Create 构建按日分割日志 hook
 func (factory *rotateHookFactory) Create(args ...interface{}) (log.Hook, error) {
 	if len(args) == 0 {
-		return factory.newLfsHook(factory.getDefaultOption()), nil
+		return factory.newLfsHook(nil)
 	}
-	var options = NewOption(args[0])
-	return factory.newLfsHook(options), nil
+	var options, err = NewOptionE(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return factory.newLfsHook(options)
 }
 
-func (factory *rotateHookFactory) getDefaultOption() *Options {
+// getDefaultOption 环境变量构建默认参数, 解析失败时不缓存
+func (factory *rotateHookFactory) getDefaultOption() (*Options, error) {
 	if factory.defaultOption == nil {
-		factory.defaultOption = CreateOptionsWithEnv(utils.UpperCase)
+		var options, err = CreateOptionsWithEnvE(utils.UpperCase)
+		if err != nil {
+			return nil, err
+		}
+		factory.defaultOption = options
 	}
-	return factory.defaultOption
+	return factory.defaultOption, nil
 }
 
 func (factory *rotateHookFactory) Face() string {
@@ -55,33 +71,143 @@ func (factory *rotateHookFactory) SetDefaultOption(options *Options) *rotateHook
 	return factory
 }
 
-func (factory *rotateHookFactory) newLfsHook(options *Options) log.Hook {
+func (factory *rotateHookFactory) newLfsHook(options *Options) (log.Hook, error) {
+	var err error
 	if options == nil {
-		options = factory.getDefaultOption()
+		if options, err = factory.getDefaultOption(); err != nil {
+			return nil, err
+		}
 	}
-	var (
-		optArr      = options.parse()
-		writer, err = rotatelogs.New(options.GetLinkName(), optArr...)
-	)
+	level, err := options.GetLevel()
+	if err != nil {
+		log.Errorf("config level for logger error: %v", err)
+		return nil, err
+	}
+	log.SetLevel(level)
+	writerMap, err := newWriterMap(options)
 	if err != nil {
 		log.Errorf("config local file system for logger error: %v", err)
+		return nil, err
 	}
-	var (
-		levels        = entity.GetLevels()
-		levelEnum, ok = levels.Get(options.Level)
-	)
-	if !ok {
-		log.SetLevel(log.WarnLevel)
-	} else {
-		log.SetLevel(entity.LogLevelOf(&levelEnum))
+	guard, err := newDiskGuard(options)
+	if err != nil {
+		log.Errorf("config disk guard for logger error: %v", err)
+		closeWriters(writerMap)
+		return nil, err
 	}
 	var (
 		formatter = &log.TextFormatter{DisableColors: options.DisableColors}
-		writerMap = lfshook.WriterMap{
-			log.DebugLevel: writer, log.InfoLevel: writer, log.WarnLevel: writer,
-			log.ErrorLevel: writer, log.FatalLevel: writer, log.PanicLevel: writer,
+		hook      = new(rotateHook)
+	)
+	hook.Hook = lfshook.NewHook(writerMap, formatter)
+	hook.guard = guard
+	hook.writers = uniqueWriters(writerMap)
+	hook.writerMap = writerMap
+	if options.CrashLogName != "" {
+		if hook.crash, err = CaptureCrash(options); err != nil {
+			log.Errorf("config crash capture for logger error: %v", err)
+			_ = hook.Close()
+			return nil, err
+		}
+	}
+	return hook, nil
+}
+
+func (hook *rotateHook) Fire(entry *log.Entry) error {
+	if hook.guard != nil && entry != nil && !hook.guard.Allow(entry.Level) {
+		return nil
+	}
+	if err := hook.Hook.Fire(entry); err != nil {
+		return err
+	}
+	// Fatal/Panic 后进程退出, 需强制刷新缓冲
+	if writer, ok := hook.writerMap[entry.Level].(flusher); ok {
+		return writer.syncFor(entry.Level)
+	}
+	return nil
+}
+
+// Close 关闭全部写入器 (同步清单, 等待压缩/归档结束)
+func (hook *rotateHook) Close() error {
+	var last error
+	if hook.crash != nil {
+		last = hook.crash.Close()
+	}
+	for _, v := range hook.writers {
+		if err := v.Close(); err != nil {
+			last = err
 		}
-		lfsHook = lfshook.NewHook(writerMap, formatter)
+	}
+	return last
+}
+
+func uniqueWriters(writerMap lfshook.WriterMap) []io.WriteCloser {
+	var (
+		writers []io.WriteCloser
+		exists  = make(map[io.Writer]bool)
 	)
-	return lfsHook
+	for _, v := range writerMap {
+		var closer, ok = v.(io.WriteCloser)
+		if !ok || exists[v] {
+			continue
+		}
+		exists[v] = true
+		writers = append(writers, closer)
+	}
+	return writers
+}
+
+// closeWriters 构建失败时关闭已创建的写入器 (去重)
+func closeWriters(writerMap lfshook.WriterMap) {
+	for _, v := range uniqueWriters(writerMap) {
+		_ = v.Close()
+	}
+}
+
+// newWriterMap 构建各级别写入器, 按级别分文件时每个级别独立分割与保留
+func newWriterMap(options *Options) (lfshook.WriterMap, error) {
+	var levels = []log.Level{
+		log.DebugLevel, log.InfoLevel, log.WarnLevel,
+		log.ErrorLevel, log.FatalLevel, log.PanicLevel,
+	}
+	var writerMap = lfshook.WriterMap{}
+	if !options.IsSplitLevels() {
+		var writer, err = newModeWriter(options)
+		if err != nil {
+			return nil, err
+		}
+		for _, level := range levels {
+			writerMap[level] = writer
+		}
+		return writerMap, nil
+	}
+	var ages, err = options.GetLevelMaxAge()
+	if err != nil {
+		return nil, err
+	}
+	totalPattern, err := options.GetGlobPattern()
+	if err != nil {
+		return nil, err
+	}
+	for _, level := range levels {
+		var writer, err = newModeWriter(options.ForLevel(level, ages[level]))
+		if err != nil {
+			closeWriters(writerMap)
+			return nil, err
+		}
+		// 目录总大小按全部级别文件统计
+		if rotating, ok := writer.(*rotateWriter); ok {
+			rotating.totalPattern = totalPattern
+		}
+		writerMap[level] = writer
+	}
+	return writerMap, nil
+}
+
+// newModeWriter 按写入模式构建写入器
+func newModeWriter(options *Options) (io.WriteCloser, error) {
+	if options.GetMode() == ModeReopen {
+		return newReopenWriter(options)
+	}
+	return newRotateWriter(options)
 }
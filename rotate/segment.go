@@ -0,0 +1,308 @@
+package rotate
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 分段头尾标记行, eg: {"@segment":"header","service":"app",...}
+const (
+	SegmentMarkKey    = "@segment"
+	SegmentMarkHeader = "header"
+	SegmentMarkFooter = "footer"
+
+	checksumPrefix = "sha256:"
+)
+
+type (
+	// SegmentHeader 分段打开时写入的头部信息
+	SegmentHeader struct {
+		Mark          string    `json:"@segment"`
+		Service       string    `json:"service"`
+		Version       string    `json:"version,omitempty"`
+		Hostname      string    `json:"hostname"`
+		Pid           int       `json:"pid"`
+		StartAt       time.Time `json:"start_at"`
+		SchemaVersion string    `json:"schema_version,omitempty"`
+	}
+
+	// SegmentFooter 分段关闭时写入的尾部信息, 行数与校验和覆盖头尾之间的日志
+	SegmentFooter struct {
+		Mark     string    `json:"@segment"`
+		Lines    int64     `json:"lines"`
+		Bytes    int64     `json:"bytes"`
+		Checksum string    `json:"checksum"`
+		EndAt    time.Time `json:"end_at"`
+	}
+
+	// segmentBlock 当前写入块的统计
+	segmentBlock struct {
+		lines    int64
+		bytes    int64
+		checksum hash.Hash
+	}
+)
+
+var (
+	ErrSegmentIncomplete = errors.New("segment has no footer")
+
+	segmentMarkPrefix = []byte(`{"` + SegmentMarkKey + `":`)
+)
+
+// IsSegmentMark 是否为分段头尾标记行
+func IsSegmentMark(line string) bool {
+	return strings.HasPrefix(line, string(segmentMarkPrefix))
+}
+
+// ParseSegmentMark 解析分段标记行, 非标记行返回 nil
+func ParseSegmentMark(line string) (*SegmentHeader, *SegmentFooter, error) {
+	if !IsSegmentMark(line) {
+		return nil, nil, nil
+	}
+	var mark struct {
+		Mark string `json:"@segment"`
+	}
+	if err := json.Unmarshal([]byte(line), &mark); err != nil {
+		return nil, nil, err
+	}
+	switch mark.Mark {
+	case SegmentMarkHeader:
+		var header = new(SegmentHeader)
+		return header, nil, json.Unmarshal([]byte(line), header)
+	case SegmentMarkFooter:
+		var footer = new(SegmentFooter)
+		return nil, footer, json.Unmarshal([]byte(line), footer)
+	}
+	return nil, nil, fmt.Errorf("unknown segment mark %q", mark.Mark)
+}
+
+// VerifySegment 校验分段 (支持 .gz 与加密分段) 每个头尾块的行数与校验和, 缺少尾部时返回 ErrSegmentIncomplete
+func VerifySegment(name string, keys *KeyRing) ([]*SegmentHeader, error) {
+	var reader, err = OpenSegment(name, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var (
+		headers []*SegmentHeader
+		block   *segmentBlock
+		buf     = bufio.NewReader(reader)
+		lineNo  int
+	)
+	for {
+		var line, err = buf.ReadBytes('\n')
+		if len(line) > 0 {
+			lineNo++
+			header, footer, markErr := ParseSegmentMark(strings.TrimRight(string(line), "\r\n"))
+			switch {
+			case markErr != nil:
+				return headers, fmt.Errorf("%s:%d: %v", name, lineNo, markErr)
+			case header != nil:
+				if block != nil {
+					return headers, fmt.Errorf("%s:%d: %w", name, lineNo, ErrSegmentIncomplete)
+				}
+				headers = append(headers, header)
+				block = newSegmentBlock()
+			case footer != nil:
+				if block == nil {
+					return headers, fmt.Errorf("%s:%d: footer without header", name, lineNo)
+				}
+				if err := block.verify(footer); err != nil {
+					return headers, fmt.Errorf("%s:%d: %v", name, lineNo, err)
+				}
+				block = nil
+			case block != nil:
+				block.add(line)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return headers, fmt.Errorf("read %s: %v", name, err)
+		}
+	}
+	if block != nil {
+		return headers, fmt.Errorf("%s: %w", name, ErrSegmentIncomplete)
+	}
+	return headers, nil
+}
+
+// segmentReader 分段读取器, 关闭时依次关闭解压层与文件
+type segmentReader struct {
+	io.Reader
+	closers []io.Closer
+	decrypt *decryptReader
+}
+
+// OpenSegment 打开分段, 自动解密 (需提供密钥) 与解压 .gz 分段
+func OpenSegment(name string, keys *KeyRing) (io.ReadCloser, error) {
+	var file, err = os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		buf    = bufio.NewReader(file)
+		reader = &segmentReader{Reader: buf, closers: []io.Closer{file}}
+	)
+	if IsEncrypted(buf) {
+		if keys == nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("open %s: %w: segment is encrypted", name, ErrKeyNotFound)
+		}
+		reader.decrypt = NewDecryptReader(buf, keys).(*decryptReader)
+		reader.Reader = reader.decrypt
+	}
+	if strings.HasSuffix(strings.TrimSuffix(name, EncryptExt), GzipExt) {
+		gz, err := gzip.NewReader(reader.Reader)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("open %s: %v", name, err)
+		}
+		reader.Reader = gz
+		reader.closers = append([]io.Closer{gz}, reader.closers...)
+	}
+	return reader, nil
+}
+
+// segmentExts 分段关闭后可能的压缩/加密后缀
+var segmentExts = []string{GzipExt, EncryptExt, GzipExt + EncryptExt}
+
+// globSegments 查找分段及其压缩/加密文件
+func globSegments(pattern string) ([]string, error) {
+	var matches, err = filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, ext := range segmentExts {
+		if others, err := filepath.Glob(pattern + ext); err == nil {
+			matches = append(matches, others...)
+		}
+	}
+	return matches, nil
+}
+
+// segmentProcessed 分段是否已被压缩或加密
+func segmentProcessed(name string) bool {
+	for _, ext := range segmentExts {
+		if _, err := os.Stat(name + ext); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentBaseName 去除压缩/加密后缀的分段文件名
+func segmentBaseName(path string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), EncryptExt), GzipExt)
+}
+
+// Unsealed 加密分段末尾的会话缺少结束块, 见 IsUnsealed
+func (reader *segmentReader) Unsealed() bool {
+	return reader.decrypt != nil && reader.decrypt.Unsealed()
+}
+
+func (reader *segmentReader) Close() error {
+	var last error
+	for _, v := range reader.closers {
+		if err := v.Close(); err != nil {
+			last = err
+		}
+	}
+	return last
+}
+
+func newSegmentBlock() *segmentBlock {
+	return &segmentBlock{checksum: sha256.New()}
+}
+
+func (block *segmentBlock) add(p []byte) {
+	block.lines += int64(bytes.Count(p, []byte{'\n'}))
+	block.bytes += int64(len(p))
+	block.checksum.Write(p)
+}
+
+func (block *segmentBlock) sum() string {
+	return checksumPrefix + hex.EncodeToString(block.checksum.Sum(nil))
+}
+
+func (block *segmentBlock) verify(footer *SegmentFooter) error {
+	if footer.Lines != block.lines || footer.Bytes != block.bytes {
+		return fmt.Errorf("segment truncated: footer lines=%d bytes=%d, read lines=%d bytes=%d",
+			footer.Lines, footer.Bytes, block.lines, block.bytes)
+	}
+	if sum := block.sum(); footer.Checksum != sum {
+		return fmt.Errorf("segment checksum mismatch: footer %s, read %s", footer.Checksum, sum)
+	}
+	return nil
+}
+
+// newSegmentHeader 按配置生成头部模板, 未开启时返回 nil
+func newSegmentHeader(options *Options) *SegmentHeader {
+	if !options.SegmentHeader {
+		return nil
+	}
+	var (
+		hostname, _ = os.Hostname()
+		service     = options.ServiceName
+	)
+	if service == "" {
+		service = strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+	}
+	return &SegmentHeader{
+		Mark:          SegmentMarkHeader,
+		Service:       service,
+		Version:       options.ServiceVersion,
+		Hostname:      hostname,
+		Pid:           os.Getpid(),
+		SchemaVersion: options.SchemaVersion,
+	}
+}
+
+// writeHeader 新分段打开后写入头部并开始统计
+func (writer *rotateWriter) writeHeader() {
+	if writer.header == nil || writer.file == nil {
+		return
+	}
+	var header = *writer.header
+	header.StartAt = writer.clock.Now()
+	writer.writeMark(&header)
+	writer.block = newSegmentBlock()
+}
+
+// writeFooter 分段关闭前写入尾部
+func (writer *rotateWriter) writeFooter() {
+	if writer.block == nil || writer.file == nil {
+		return
+	}
+	writer.writeMark(&SegmentFooter{
+		Mark:     SegmentMarkFooter,
+		Lines:    writer.block.lines,
+		Bytes:    writer.block.bytes,
+		Checksum: writer.block.sum(),
+		EndAt:    writer.clock.Now(),
+	})
+	writer.block = nil
+}
+
+func (writer *rotateWriter) writeMark(mark interface{}) {
+	var line, err = json.Marshal(mark)
+	if err != nil {
+		writer.report(err)
+		return
+	}
+	_, err = writer.writeFile(append(line, '\n'))
+	writer.report(err)
+}
@@ -0,0 +1,60 @@
+package rotate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateWriter_SegmentHeader(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+	)
+	options.SegmentHeader = true
+	options.ServiceName = "billing"
+	options.ServiceVersion = "1.2.0"
+	options.SchemaVersion = "v2"
+	writer, err := newRotateWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n"} {
+		if _, err = writer.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Add(24 * time.Hour)
+	if _, err = writer.Write([]byte("third\n")); err != nil {
+		t.Fatal(err)
+	}
+	var name = filepath.Join(dir, "app-20210102.log")
+	if _, err = VerifySegment(name, nil); !errors.Is(err, ErrSegmentIncomplete) {
+		t.Errorf("expect open segment incomplete, got %v", err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	headers, err := VerifySegment(filepath.Join(dir, "app-20210101.log"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 1 || headers[0].Service != "billing" || headers[0].Version != "1.2.0" ||
+		headers[0].SchemaVersion != "v2" || headers[0].Pid != os.Getpid() || !headers[0].StartAt.Equal(clock.Now().Add(-24*time.Hour)) {
+		t.Errorf("unexpected header %+v", headers)
+	}
+	if _, err = VerifySegment(name, nil); err != nil {
+		t.Errorf("closed segment invalid: %v", err)
+	}
+	// 篡改日志行后校验失败
+	var content, _ = ioutil.ReadFile(name)
+	_ = ioutil.WriteFile(name, []byte(strings.Replace(string(content), "third", "THIRD", 1)), 0644)
+	if _, err = VerifySegment(name, nil); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("expect checksum mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package rotate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// maxSharedAttempts 共享写入时切换分段的最大重试次数
+	maxSharedAttempts = 16
+)
+
+var (
+	errSharedUnsupported = errors.New("shared mode (flock) unsupported on this platform")
+)
+
+// openSharedLocks 打开多进程协调使用的锁文件, 写入与后台任务各持一个文件描述符
+func (writer *rotateWriter) openSharedLocks() error {
+	if !sharedSupported {
+		return errSharedUnsupported
+	}
+	var name = writer.linkName
+	if name == "" {
+		name = writer.globPattern
+	}
+	var (
+		dir      = filepath.Dir(name)
+		lockName = filepath.Join(dir, "."+filepath.Base(name)+".lock")
+	)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var lock, err = os.OpenFile(lockName, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file %s: %v", lockName, err)
+	}
+	postLock, err := os.OpenFile(lockName, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		_ = lock.Close()
+		return fmt.Errorf("failed to open lock file %s: %v", lockName, err)
+	}
+	writer.lockFile = lock
+	writer.postLockFile = postLock
+	return nil
+}
+
+// writeShared 持共享锁单次 O_APPEND 写入, 需要切换分段时升级为排他锁
+func (writer *rotateWriter) writeShared(p []byte) (int, error) {
+	var n = int64(len(p))
+	for i := 0; i < maxSharedAttempts; i++ {
+		if err := lockFile(writer.lockFile, true); err != nil {
+			return 0, err
+		}
+		if !writer.sharedStale(n) {
+			var written, err = writer.write(p)
+			_ = unlockFile(writer.lockFile)
+			return written, err
+		}
+		_ = unlockFile(writer.lockFile)
+		var err = writer.exclusive(writer.lockFile, func() error {
+			return writer.rotateShared(n)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("rotate: no writable segment for %s after %d attempts", writer.baseName, maxSharedAttempts)
+}
+
+// sharedStale 当前分段是否需要切换: 周期变化, 已被其他进程删除或大小超限
+func (writer *rotateWriter) sharedStale(n int64) bool {
+	if writer.file == nil || writer.genBaseName() != writer.baseName {
+		return true
+	}
+	var info, err = writer.file.Stat()
+	if err != nil || isUnlinked(info) {
+		return true
+	}
+	writer.size = info.Size()
+	return writer.rotationSize > 0 && writer.size > 0 && writer.size+n > writer.rotationSize
+}
+
+// rotateShared 持排他锁重新选择分段, 各进程按相同规则选中同一文件
+func (writer *rotateWriter) rotateShared(n int64) error {
+	var (
+		baseName   = writer.genBaseName()
+		generation = 0
+	)
+	if writer.file != nil && baseName == writer.baseName {
+		generation = writer.generation
+	}
+	// 跳过剩余空间不足的分段, 避免逐个重试
+	for writer.rotationSize > 0 {
+		var name = writer.segmentName(baseName, generation)
+		if segmentProcessed(name) {
+			generation++
+			continue
+		}
+		var info, err = os.Stat(name)
+		if err != nil || info.Size() == 0 || info.Size()+n <= writer.rotationSize {
+			break
+		}
+		generation++
+	}
+	if writer.file != nil && baseName == writer.baseName && generation == writer.generation {
+		if info, err := writer.file.Stat(); err == nil && !isUnlinked(info) {
+			return nil
+		}
+	}
+	return writer.open(baseName, generation, false)
+}
+
+// exclusive 持排他锁执行, 非共享模式直接执行
+func (writer *rotateWriter) exclusive(lock *os.File, fn func() error) error {
+	if !writer.shared || lock == nil {
+		return fn()
+	}
+	if err := lockFile(lock, false); err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+	return fn()
+}
+
+func (writer *rotateWriter) closeSharedLocks() {
+	for _, v := range []*os.File{writer.lockFile, writer.postLockFile} {
+		if v != nil {
+			_ = v.Close()
+		}
+	}
+	writer.lockFile, writer.postLockFile = nil, nil
+}
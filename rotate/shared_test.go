@@ -0,0 +1,120 @@
+package rotate
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	sharedHelperEnv   = "ROTATE_SHARED_HELPER"
+	sharedHelperLines = 500
+)
+
+// TestSharedHelper 子进程入口, 由 TestRotateWriter_SharedProcesses 启动
+func TestSharedHelper(t *testing.T) {
+	var dir = os.Getenv(sharedHelperEnv)
+	if dir == "" {
+		t.Skip("helper process only")
+	}
+	var options = CreateOptionsWithLogName(filepath.Join(dir, "app.log"))
+	options.Shared = true
+	options.RotationSize = 4096
+	options.RotationCount = 1000
+	writer, err := newRotateWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id = os.Getenv(sharedHelperEnv + "_ID")
+	for i := 0; i < sharedHelperLines; i++ {
+		var line = fmt.Sprintf("%s %d %s\n", id, i, strings.Repeat("x", 40))
+		if _, err = writer.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateWriter_SharedProcesses(t *testing.T) {
+	if !sharedSupported {
+		t.Skip(errSharedUnsupported)
+	}
+	var (
+		dir       = t.TempDir()
+		processes = 4
+		commands  []*exec.Cmd
+	)
+	for i := 0; i < processes; i++ {
+		var cmd = exec.Command(os.Args[0], "-test.run=^TestSharedHelper$")
+		cmd.Env = append(os.Environ(), sharedHelperEnv+"="+dir, sharedHelperEnv+"_ID=p"+strconv.Itoa(i))
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		commands = append(commands, cmd)
+	}
+	for _, cmd := range commands {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("helper process failed: %v", err)
+		}
+	}
+	var (
+		seen         = make(map[string]bool)
+		matches, err = filepath.Glob(filepath.Join(dir, "app-*"))
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) < 2 {
+		t.Fatalf("expect rotated segments, got %v", matches)
+	}
+	for _, name := range matches {
+		readSharedSegment(t, name, seen)
+	}
+	for i := 0; i < processes; i++ {
+		for j := 0; j < sharedHelperLines; j++ {
+			if key := fmt.Sprintf("p%d %d", i, j); !seen[key] {
+				t.Errorf("line %q missing", key)
+			}
+		}
+	}
+}
+
+func readSharedSegment(t *testing.T, name string, seen map[string]bool) {
+	var file, err = os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(name, GzipExt) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	var scanner = bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var fields = strings.Fields(scanner.Text())
+		if len(fields) != 3 || len(fields[2]) != 40 {
+			t.Errorf("%s: interleaved line %q", filepath.Base(name), scanner.Text())
+			continue
+		}
+		var key = fields[0] + " " + fields[1]
+		if seen[key] {
+			t.Errorf("duplicated line %q", key)
+		}
+		seen[key] = true
+	}
+}
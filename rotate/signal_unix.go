@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package rotate
+
+import (
+	"os"
+	"syscall"
+)
+
+// reopenSignals 触发重新打开日志文件的信号
+var reopenSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1}
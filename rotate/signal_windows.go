@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package rotate
+
+import "os"
+
+// reopenSignals windows 不支持 SIGHUP/SIGUSR1, 仅支持 Reopen 调用
+var reopenSignals []os.Signal
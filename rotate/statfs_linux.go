@@ -0,0 +1,15 @@
+//go:build linux
+// +build linux
+
+package rotate
+
+import "golang.org/x/sys/unix"
+
+// statfs 查询目录所在卷的可用空间与总空间
+func statfs(path string) (free uint64, total uint64, err error) {
+	var stat unix.Statfs_t
+	if err = unix.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package rotate
+
+// statfs 非 linux 平台不支持, 磁盘守护不生效
+func statfs(path string) (free uint64, total uint64, err error) {
+	return 0, 0, errStatfsUnsupported
+}
@@ -0,0 +1,616 @@
+package rotate
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/strftime"
+)
+
+type (
+	// rotateWriter 原生日志分割写入器 (按时间/大小分割, 软链接, 压缩, 保留策略)
+	rotateWriter struct {
+		locker        sync.Mutex
+		clock         Clock
+		pattern       *strftime.Strftime
+		globPattern   string
+		linkName      string
+		rotationTime  time.Duration
+		rotationSize  int64
+		rotationCount uint
+		maxAge        time.Duration
+		compress      bool
+		maxTotalSize  int64
+		totalPattern  string
+		onRemove      RemoveHandler
+		archiver      Archiver
+		archiveRemove bool
+		pending       map[string]bool
+		manifest      bool
+		firstAt       time.Time
+		lastAt        time.Time
+		lines         int64
+		shared        bool
+		header        *SegmentHeader
+		onOpen        func(previous, name string)
+		encrypt       string
+		keys          *KeyRing
+		sealer        *chunkSealer
+		buf           *bufio.Writer
+		bufSize       int
+		flushInterval time.Duration
+		fsync         string
+		fsyncInterval time.Duration
+		stop          chan struct{}
+		block         *segmentBlock
+		lockFile      *os.File
+		postLockFile  *os.File
+		file          *os.File
+		baseName      string
+		fileName      string
+		generation    int
+		size          int64
+		waiter        sync.WaitGroup
+		postLocker    sync.Mutex
+		taskLocker    sync.Mutex
+		closed        []string
+	}
+
+	// segmentInfo 日志分段文件信息
+	segmentInfo struct {
+		path    string
+		modTime time.Time
+		size    int64
+	}
+)
+
+const (
+	GzipExt       = ".gz"
+	defaultMaxAge = 7 * 24 * time.Hour
+)
+
+var (
+	patternConversion = regexp.MustCompile(`%[%+A-Za-z]`)
+)
+
+func newRotateWriter(options *Options) (*rotateWriter, error) {
+	if options == nil {
+		return nil, errors.New("rotate writer options missing")
+	}
+	var layout, err = options.GetLayout()
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := strftime.New(layout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log name layout %s: %v", layout, err)
+	}
+	globPattern, err := options.GetGlobPattern()
+	if err != nil {
+		return nil, err
+	}
+	var writer = new(rotateWriter)
+	writer.pattern = pattern
+	writer.globPattern = globPattern
+	writer.linkName = options.GetLinkFile()
+	writer.clock = options.GetClock()
+	writer.rotationTime = options.RotationTime
+	writer.rotationSize = options.RotationSize
+	writer.maxAge = options.MaxAge
+	writer.compress = options.Compress
+	writer.maxTotalSize = options.MaxTotalSize
+	writer.totalPattern = writer.globPattern
+	writer.onRemove = options.GetRemoveHandler()
+	writer.archiveRemove = options.ArchiveRemove
+	writer.pending = make(map[string]bool)
+	writer.manifest = options.Manifest
+	if writer.archiver, err = options.GetArchiver(); err != nil {
+		return nil, err
+	}
+	// MaxAge 与 RotationCount 只生效一个, MaxAge 优先
+	if writer.maxAge <= 0 {
+		writer.rotationCount = options.RotationCount
+	}
+	if writer.maxAge <= 0 && writer.rotationCount <= 0 {
+		writer.maxAge = defaultMaxAge
+	}
+	writer.header = newSegmentHeader(options)
+	if writer.encrypt, writer.keys, err = options.getEncryption(); err != nil {
+		return nil, err
+	}
+	if options.Shared {
+		// 多进程交错写入时无法按块统计或分块加密
+		if writer.header != nil {
+			return nil, errors.New("segment header is not supported in shared mode")
+		}
+		if writer.encrypt == EncryptLive {
+			return nil, errors.New("live encryption is not supported in shared mode")
+		}
+		writer.shared = true
+		if err = writer.openSharedLocks(); err != nil {
+			return nil, err
+		}
+	}
+	if err = writer.initBuffer(options); err != nil {
+		writer.closeSharedLocks()
+		return nil, err
+	}
+	// 启动时强制切换, 本次运行从新分段开始, 上次运行的最后分段交由后台压缩/加密/归档
+	if options.RotateOnStartup {
+		var last = writer.lastSegment()
+		if err = writer.Rotate(); err != nil {
+			writer.stopBuffer()
+			writer.closeSharedLocks()
+			return nil, err
+		}
+		if last != "" {
+			writer.finish(last)
+		}
+	}
+	return writer, nil
+}
+
+// lastSegment 上次运行留下的最新分段, 已处理, 正在写入或共享模式 (可能仍有其他进程写入) 时为空
+func (writer *rotateWriter) lastSegment() string {
+	if writer.shared {
+		return ""
+	}
+	var segments = writer.match(writer.globPattern, "")
+	if len(segments) <= 0 {
+		return ""
+	}
+	var name = segments[len(segments)-1].path
+	if filepath.Base(name) != segmentBaseName(name) || segmentProcessed(name) || activeSegments.has(name) {
+		return ""
+	}
+	return name
+}
+
+// Write 写入日志, 达到分割时间或大小时自动切换文件
+func (writer *rotateWriter) Write(p []byte) (int, error) {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	if writer.shared {
+		return writer.writeShared(p)
+	}
+	if err := writer.prepare(int64(len(p))); err != nil {
+		return 0, err
+	}
+	return writer.write(p)
+}
+
+// writeWith 按预估长度切换分段后再生成内容写入, 内容依赖切换结果时使用 (eg: 审计哈希链)
+func (writer *rotateWriter) writeWith(n int64, build func() []byte) (int, error) {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	if writer.shared {
+		return 0, errors.New("rotate: build write is not supported in shared mode")
+	}
+	if err := writer.prepare(n); err != nil {
+		return 0, err
+	}
+	return writer.write(build())
+}
+
+// write 单次写入当前分段并更新统计
+func (writer *rotateWriter) write(p []byte) (int, error) {
+	var n, err = writer.writeFile(p)
+	if n > 0 {
+		var now = writer.clock.Now()
+		if writer.firstAt.IsZero() {
+			writer.firstAt = now
+		}
+		writer.lastAt = now
+		writer.lines += int64(bytes.Count(p[:n], []byte{'\n'}))
+		if writer.block != nil {
+			writer.block.add(p[:n])
+		}
+	}
+	return n, err
+}
+
+// writeFile 写入当前文件 (开启缓冲时写入缓冲), 实时加密时整块加密后一次性追加, 返回明文长度
+func (writer *rotateWriter) writeFile(p []byte) (int, error) {
+	var out io.Writer = writer.file
+	if writer.buf != nil {
+		out = writer.buf
+	}
+	if writer.sealer == nil {
+		var n, err = out.Write(p)
+		writer.size += int64(n)
+		return n, err
+	}
+	var sealed, err = writer.sealer.seal(p)
+	if err != nil {
+		return 0, err
+	}
+	n, err := out.Write(sealed)
+	writer.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeSealEnd 实时加密时在关闭文件前写入会话结束块
+func (writer *rotateWriter) writeSealEnd() {
+	if writer.sealer == nil || writer.file == nil {
+		return
+	}
+	var out io.Writer = writer.file
+	if writer.buf != nil {
+		out = writer.buf
+	}
+	var end, err = writer.sealer.final()
+	if err == nil && len(end) > 0 {
+		var n int
+		n, err = out.Write(end)
+		writer.size += int64(n)
+	}
+	writer.report(err)
+}
+
+// Rotate 强制切换到新的日志分段
+func (writer *rotateWriter) Rotate() error {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	return writer.exclusive(writer.lockFile, func() error {
+		var baseName = writer.genBaseName()
+		if writer.file == nil || baseName != writer.baseName {
+			return writer.open(baseName, 0, true)
+		}
+		return writer.open(baseName, writer.generation+1, true)
+	})
+}
+
+// CurrentFileName 当前写入的日志文件
+func (writer *rotateWriter) CurrentFileName() string {
+	writer.locker.Lock()
+	defer writer.locker.Unlock()
+	return writer.fileName
+}
+
+// Close 关闭当前文件并等待后台压缩/清理结束
+func (writer *rotateWriter) Close() error {
+	writer.locker.Lock()
+	var err error
+	writer.stopBuffer()
+	if writer.file != nil {
+		writer.writeFooter()
+		writer.writeSealEnd()
+		writer.report(writer.flush())
+		err = writer.file.Close()
+		writer.file = nil
+		activeSegments.remove(writer.fileName)
+		_ = writer.exclusive(writer.lockFile, func() error {
+			writer.manifestSync(writer.fileName, false)
+			return nil
+		})
+	}
+	writer.locker.Unlock()
+	writer.waiter.Wait()
+	writer.closeSharedLocks()
+	return err
+}
+
+func (writer *rotateWriter) prepare(n int64) error {
+	var baseName = writer.genBaseName()
+	if writer.file == nil || baseName != writer.baseName {
+		return writer.open(baseName, 0, false)
+	}
+	if writer.rotationSize <= 0 || writer.size <= 0 || writer.size+n <= writer.rotationSize {
+		return nil
+	}
+	return writer.open(baseName, writer.generation+1, false)
+}
+
+// open 打开分段文件, fresh 为 true 时只使用不存在的文件名
+func (writer *rotateWriter) open(baseName string, generation int, fresh bool) error {
+	var name string
+	for {
+		name = writer.segmentName(baseName, generation)
+		if segmentProcessed(name) {
+			generation++
+			continue
+		}
+		var info, err = os.Stat(name)
+		if err != nil {
+			break
+		}
+		if !fresh && (writer.rotationSize <= 0 || info.Size() < writer.rotationSize) {
+			break
+		}
+		generation++
+	}
+	var dir = filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+	var file, err = os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %v", name, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	var (
+		previous     = writer.file
+		previousName = writer.fileName
+	)
+	activeSegments.add(name)
+	if previous != nil {
+		writer.writeFooter()
+		writer.writeSealEnd()
+		writer.report(writer.flush())
+		activeSegments.remove(previousName)
+		writer.manifestSync(previousName, true)
+	}
+	writer.file = file
+	writer.fileName = name
+	writer.baseName = baseName
+	writer.generation = generation
+	writer.size = info.Size()
+	writer.resetBuffer()
+	// 实时加密时每个打开的文件开始新的加密会话
+	if writer.encrypt == EncryptLive {
+		if writer.sealer, err = newChunkSealer(writer.keys); err != nil {
+			return err
+		}
+	}
+	writer.writeHeader()
+	if writer.onOpen != nil {
+		writer.onOpen(previousName, name)
+	}
+	writer.manifestOpen(name)
+	writer.link(name)
+	if previous != nil {
+		if err := previous.Close(); err != nil {
+			writer.report(err)
+		}
+	}
+	if previousName == name {
+		previousName = ""
+	}
+	writer.finish(previousName)
+	return nil
+}
+
+// finish 后台按切换顺序处理已关闭的分段 (压缩) 并执行保留策略
+func (writer *rotateWriter) finish(closed string) {
+	writer.taskLocker.Lock()
+	if closed != "" {
+		writer.closed = append(writer.closed, closed)
+	}
+	writer.taskLocker.Unlock()
+	writer.waiter.Add(1)
+	go func() {
+		defer writer.waiter.Done()
+		writer.postLocker.Lock()
+		defer writer.postLocker.Unlock()
+		// 先取当前文件再加锁, 避免与持锁写入互相等待
+		var current = writer.CurrentFileName()
+		_ = writer.exclusive(writer.postLockFile, func() error {
+			writer.taskLocker.Lock()
+			var closed = writer.closed
+			writer.closed = nil
+			writer.taskLocker.Unlock()
+			writer.retryArchive()
+			for _, name := range closed {
+				writer.process(name)
+			}
+			writer.cleanup(current)
+			return nil
+		})
+	}()
+}
+
+// process 压缩并归档已关闭的分段
+func (writer *rotateWriter) process(closed string) {
+	// 共享模式下可能已被其他进程处理
+	if _, err := os.Stat(closed); os.IsNotExist(err) {
+		return
+	}
+	var name = closed
+	// 实时加密的密文无需压缩
+	if writer.compress && writer.encrypt != EncryptLive {
+		if err := compressFile(closed); err != nil {
+			writer.report(err)
+		} else {
+			name = closed + GzipExt
+			writer.manifestUpdate(closed, func(manifest *Manifest, meta *SegmentMeta) {
+				meta.Path = filepath.Base(name)
+				meta.Compressed = true
+				if info, err := os.Stat(name); err == nil {
+					meta.Size = info.Size()
+				}
+			})
+		}
+	}
+	if writer.encrypt == EncryptRotated {
+		if err := encryptFile(name, writer.keys); err != nil {
+			writer.report(err)
+		} else {
+			var plain = name
+			name = plain + EncryptExt
+			writer.manifestUpdate(plain, func(manifest *Manifest, meta *SegmentMeta) {
+				meta.Path = filepath.Base(name)
+				meta.Encrypted = true
+				if info, err := os.Stat(name); err == nil {
+					meta.Size = info.Size()
+				}
+			})
+		}
+	}
+	writer.archive(name)
+}
+
+// cleanup 按 MaxAge 或 RotationCount 清理历史分段, 再执行目录总大小限制
+func (writer *rotateWriter) cleanup(current string) {
+	var (
+		segments = writer.match(writer.globPattern, current)
+		removed  = make(map[string]bool)
+	)
+	if writer.maxAge > 0 {
+		var cutoff = writer.clock.Now().Add(-writer.maxAge)
+		for _, v := range segments {
+			if v.modTime.Before(cutoff) && writer.remove(v, RemoveByMaxAge) {
+				removed[v.path] = true
+			}
+		}
+	}
+	// 保留数量包含当前写入文件
+	if writer.rotationCount > 0 && len(segments) >= int(writer.rotationCount) {
+		for _, v := range segments[:len(segments)-int(writer.rotationCount)+1] {
+			if !removed[v.path] && writer.remove(v, RemoveByRotationCount) {
+				removed[v.path] = true
+			}
+		}
+	}
+	writer.enforceTotalSize(removed)
+}
+
+// match 匹配分段列表 (不含当前文件与软链接), 按修改时间升序
+func (writer *rotateWriter) match(pattern, current string) []segmentInfo {
+	var matches, err = globSegments(pattern)
+	if err != nil {
+		writer.report(err)
+		return nil
+	}
+	var segments []segmentInfo
+	for _, v := range matches {
+		if v == current || v == writer.linkName {
+			continue
+		}
+		var info, err = os.Lstat(v)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		segments = append(segments, segmentInfo{path: v, modTime: info.ModTime(), size: info.Size()})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].modTime.Equal(segments[j].modTime) {
+			return segments[i].path < segments[j].path
+		}
+		return segments[i].modTime.Before(segments[j].modTime)
+	})
+	return segments
+}
+
+// link 为最新的日志建立软连接
+func (writer *rotateWriter) link(name string) {
+	if writer.linkName == "" || writer.linkName == name {
+		return
+	}
+	// 同名普通文件不覆盖
+	if info, err := os.Lstat(writer.linkName); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		writer.report(fmt.Errorf("link name %s exists and is not a symlink", writer.linkName))
+		return
+	}
+	var (
+		dest    = name
+		linkDir = filepath.Dir(writer.linkName)
+		tmpName = name + "_symlink"
+	)
+	if filepath.IsAbs(name) == filepath.IsAbs(writer.linkName) {
+		if rel, err := filepath.Rel(linkDir, name); err == nil {
+			dest = rel
+		}
+	}
+	if err := os.MkdirAll(linkDir, 0755); err != nil {
+		writer.report(err)
+		return
+	}
+	_ = os.Remove(tmpName)
+	if err := os.Symlink(dest, tmpName); err != nil {
+		writer.report(err)
+		return
+	}
+	if err := os.Rename(tmpName, writer.linkName); err != nil {
+		_ = os.Remove(tmpName)
+		writer.report(err)
+	}
+}
+
+func (writer *rotateWriter) genBaseName() string {
+	var (
+		now  = writer.clock.Now()
+		base time.Time
+	)
+	// Truncate 按 UTC 语义计算, 非 UTC 时区先按本地时间换算再截断
+	if now.Location() != time.UTC {
+		base = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), time.UTC)
+		base = base.Truncate(writer.rotationTime)
+		base = time.Date(base.Year(), base.Month(), base.Day(), base.Hour(), base.Minute(), base.Second(), base.Nanosecond(), now.Location())
+	} else {
+		base = now.Truncate(writer.rotationTime)
+	}
+	return writer.pattern.FormatString(base)
+}
+
+// segmentName 分段文件名, eg: app-20210101.log, app-20210101.1.log
+func (writer *rotateWriter) segmentName(baseName string, generation int) string {
+	if generation <= 0 {
+		return baseName
+	}
+	var ext = filepath.Ext(baseName)
+	return strings.TrimSuffix(baseName, ext) + "." + strconv.Itoa(generation) + ext
+}
+
+// report 写入器内部错误输出到 stderr, 避免经由 logger 递归写入
+func (writer *rotateWriter) report(err error) {
+	if err == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "rotate: %v\n", err)
+}
+
+// compressFile gzip 压缩文件并删除原文件
+func compressFile(name string) error {
+	var src, err = os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	var tmpName = name + GzipExt + ".tmp"
+	dst, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	var gz = gzip.NewWriter(dst)
+	gz.Name = filepath.Base(name)
+	gz.ModTime = info.ModTime()
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err = os.Rename(tmpName, name+GzipExt); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	_ = os.Chtimes(name+GzipExt, info.ModTime(), info.ModTime())
+	return os.Remove(name)
+}
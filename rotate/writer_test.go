@@ -0,0 +1,196 @@
+package rotate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func (clock *fakeClock) Add(d time.Duration) {
+	clock.now = clock.now.Add(d)
+}
+
+func newTestOptions(dir string, clock Clock) *Options {
+	var options = CreateOptionsWithLogName(filepath.Join(dir, "app.log"))
+	options.SetClock(clock)
+	return options
+}
+
+func TestRotateWriter_RotationTime(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+	)
+	writer, err := newRotateWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if _, err = writer.Write([]byte("day one\n")); err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(24 * time.Hour)
+	if _, err = writer.Write([]byte("day two\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"app-20210101.log", "app-20210102.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("segment %s missing: %v", name, err)
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(dir, "app.log")); err != nil || link != "app-20210102.log" {
+		t.Errorf("link name points to %q: %v", link, err)
+	}
+}
+
+func TestRotateWriter_RotationSize(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+		line    = []byte(strings.Repeat("x", 9) + "\n")
+	)
+	options.RotationSize = 25
+	writer, err := newRotateWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err = writer.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"app-20210101.log", "app-20210101.1.log", "app-20210101.2.log"} {
+		var info, err = os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("segment %s missing: %v", name, err)
+			continue
+		}
+		if info.Size() > options.RotationSize {
+			t.Errorf("segment %s exceeds rotation size: %d", name, info.Size())
+		}
+	}
+}
+
+func TestRotateWriter_RetentionAndCompress(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+	)
+	options.RotationCount = 2
+	options.Compress = true
+	writer, err := newRotateWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err = writer.Write([]byte("line\n")); err != nil {
+			t.Fatal(err)
+		}
+		// 修改时间与时钟对齐, 保证保留顺序
+		_ = os.Chtimes(writer.CurrentFileName(), clock.Now(), clock.Now())
+		clock.Add(24 * time.Hour)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var matches, _ = filepath.Glob(filepath.Join(dir, "app-*"))
+	if len(matches) != 2 {
+		t.Fatalf("expect 2 segments kept, got %v", matches)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app-20210103.log"+GzipExt)); err != nil {
+		t.Errorf("closed segment not compressed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app-20210104.log")); err != nil {
+		t.Errorf("current segment missing: %v", err)
+	}
+}
+
+func TestRotateWriter_StartupAndSession(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+	)
+	if err := os.WriteFile(filepath.Join(dir, "app-20210101.log"), []byte("previous run\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	options.RotateOnStartup = true
+	options.Compress = true
+	writer, err := newRotateWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := filepath.Base(writer.CurrentFileName()); name != "app-20210101.1.log" {
+		t.Errorf("expect fresh segment on startup, got %s", name)
+	}
+	_ = writer.Close()
+	// 上次运行的分段经过压缩处理
+	if _, err = os.Stat(filepath.Join(dir, "app-20210101.log"+GzipExt)); err != nil {
+		t.Errorf("previous segment not processed: %v", err)
+	}
+	options.RotateOnStartup = false
+	options.Compress = false
+	options.SessionNaming = true
+	if writer, err = newRotateWriter(options); err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if _, err = writer.Write([]byte("line\n")); err != nil {
+		t.Fatal(err)
+	}
+	var expect = "app-20210101-" + processStartAt.Format(sessionLayout) + ".log"
+	if name := filepath.Base(writer.CurrentFileName()); name != expect {
+		t.Errorf("expect session segment %s, got %s", expect, name)
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		clock   = &fakeClock{now: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)}
+		options = newTestOptions(dir, clock)
+		logger  = log.New()
+	)
+	options.BufferSize = 4096
+	writer, err := NewWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := exitFlushers.writers[writer.(*outputWriter).WriteCloser.(flusher)]; !ok || !exitFlushers.registered {
+		t.Error("buffered writer not registered for exit flush")
+	}
+	logger.SetOutput(writer)
+	logger.WithField("user", "alice").Info("written once")
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(exitFlushers.writers); n != 0 {
+		t.Errorf("closed writer still registered for exit flush: %d", n)
+	}
+	var content, _ = os.ReadFile(filepath.Join(dir, "app-20210101.log"))
+	if strings.Count(string(content), "written once") != 1 || strings.Contains(string(content), "\x1b[") {
+		t.Errorf("unexpected segment content %q", content)
+	}
+	options.SplitLevels = true
+	if _, err = NewWriter(options); err == nil {
+		t.Error("expect split levels error")
+	}
+}
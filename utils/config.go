@@ -0,0 +1,355 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// 配置来源, 优先级由低到高: 标签默认值 < 配置文件 < 环境变量 < 显式覆盖
+const (
+	SourceNone     ConfigSource = ""
+	SourceDefault  ConfigSource = "default"
+	SourceFile     ConfigSource = "file"
+	SourceEnv      ConfigSource = "env"
+	SourceOverride ConfigSource = "override"
+)
+
+type (
+	// ConfigSource 字段取值来源
+	ConfigSource string
+
+	// ConfigLoader 分层加载配置并记录每个字段的来源, 字段通过 env 标签声明
+	ConfigLoader struct {
+		decoder   *envTagDecoder
+		file      string
+		data      []byte
+		overrides map[string]interface{}
+		fields    []*configField
+	}
+
+	configField struct {
+		token  *tagToken
+		source ConfigSource
+		// detail 来源说明: 环境变量名, 配置文件名等
+		detail string
+	}
+)
+
+func NewConfigLoader(caseMode ...CaseMode) *ConfigLoader {
+	var loader = new(ConfigLoader)
+	loader.decoder = NewEnvDecoder(caseMode...)
+	loader.overrides = make(map[string]interface{})
+	return loader
+}
+
+// SetPrefix 环境变量前缀
+func (loader *ConfigLoader) SetPrefix(prefix string) *ConfigLoader {
+	loader.decoder.SetPrefix(prefix)
+	return loader
+}
+
+// SetSuffix 环境变量后缀
+func (loader *ConfigLoader) SetSuffix(suffix string) *ConfigLoader {
+	loader.decoder.SetSuffix(suffix)
+	return loader
+}
+
+// SetLookup 环境变量层的查找源, 默认读取进程环境变量
+func (loader *ConfigLoader) SetLookup(source EnvLookup) *ConfigLoader {
+	loader.decoder.SetLookup(source)
+	return loader
+}
+
+// SetFile json 配置文件, 文件不存在时跳过该层
+func (loader *ConfigLoader) SetFile(name string) *ConfigLoader {
+	loader.file = name
+	return loader
+}
+
+// SetData json 配置内容, 与 SetFile 同层, 同时设置时以内容为准
+func (loader *ConfigLoader) SetData(data []byte) *ConfigLoader {
+	loader.data = data
+	return loader
+}
+
+// Override 显式覆盖字段, field 为字段路径 (eg: Level, Data.Info.ID), 字符串值按 env 规则解码
+func (loader *ConfigLoader) Override(field string, value interface{}) *ConfigLoader {
+	loader.overrides[field] = value
+	return loader
+}
+
+// Load 依次加载默认值, 配置文件, 环境变量, 显式覆盖, 返回各层的解析/校验错误
+func (loader *ConfigLoader) Load(v interface{}) error {
+	var tokens = loader.decoder.parse(v)
+	if len(tokens) <= 0 {
+		return fmt.Errorf("config %T has no env tagged field", v)
+	}
+	loader.fields = loader.fields[:0]
+	for _, token := range tokens {
+		if token == nil || token.Key == "" || token.value == nil {
+			continue
+		}
+		loader.fields = append(loader.fields, &configField{token: token})
+	}
+	var errs EnvErrors
+	errs = append(errs, loader.loadDefault()...)
+	fileErrs, err := loader.loadFile(v)
+	if err != nil {
+		return err
+	}
+	errs = append(errs, fileErrs...)
+	errs = append(errs, loader.loadEnv()...)
+	errs = append(errs, loader.loadOverride()...)
+	errs = append(errs, loader.checkRequired()...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (loader *ConfigLoader) loadDefault() EnvErrors {
+	var errs EnvErrors
+	for _, field := range loader.fields {
+		var token = field.token
+		if token.err != nil {
+			errs = append(errs, loader.fieldError(token, "", token.err))
+			continue
+		}
+		var data = loader.decoder.expand(token.Default)
+		if data == "" {
+			continue
+		}
+		if err := loader.decoder.set(token.value, data); err != nil {
+			errs = append(errs, loader.fieldError(token, data, err))
+			continue
+		}
+		field.source, field.detail = SourceDefault, "tag"
+	}
+	return errs
+}
+
+// loadFile 按 json 名称路径判断配置文件提供的字段
+// loadFile 加载 json 文件层, 文件中出现的字段按标签规则校验, 错误的 Key 为文件名
+func (loader *ConfigLoader) loadFile(v interface{}) (EnvErrors, error) {
+	var (
+		data = loader.data
+		name = "data"
+	)
+	if data == nil && loader.file != "" {
+		var bytes, err = ioutil.ReadFile(loader.file)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, name = bytes, loader.file
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("config %s: %v", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("config %s: %v", name, err)
+	}
+	var errs EnvErrors
+	for _, field := range loader.fields {
+		var value, ok = jsonPathValue(values, field.token.json)
+		if !ok {
+			continue
+		}
+		field.source, field.detail = SourceFile, name
+		var raw = jsonText(value)
+		if err := field.token.rules.check(field.token.value, raw); err != nil {
+			if field.token.secret {
+				raw = secretMask
+			}
+			errs = append(errs, &EnvFieldError{
+				Key:   name,
+				Value: raw,
+				Type:  field.token.value.Type().String(),
+				Err:   fmt.Errorf("%s: %v", strings.Join(field.token.json, "."), err),
+			})
+		}
+	}
+	return errs, nil
+}
+
+func (loader *ConfigLoader) loadEnv() EnvErrors {
+	var errs EnvErrors
+	for _, field := range loader.fields {
+		var token = field.token
+		if token.err != nil {
+			continue
+		}
+		var key, data, err = loader.decoder.lookup(token.Key)
+		if err == nil && data == "" {
+			continue
+		}
+		if err == nil {
+			err = loader.decoder.decode(token, data)
+		}
+		if err != nil {
+			errs = append(errs, loader.decoder.fieldError(token, key, data, err))
+			continue
+		}
+		field.source, field.detail = SourceEnv, key
+	}
+	return errs
+}
+
+func (loader *ConfigLoader) loadOverride() EnvErrors {
+	var errs EnvErrors
+	for name, value := range loader.overrides {
+		var field = loader.field(name)
+		if field == nil {
+			errs = append(errs, &EnvFieldError{Key: name, Value: fmt.Sprint(value), Type: "unknown", Err: fmt.Errorf("no env tagged field %s", name)})
+			continue
+		}
+		var err = loader.assign(field.token, value)
+		if err == nil {
+			err = field.token.rules.check(field.token.value, fmt.Sprint(value))
+		}
+		if err != nil {
+			errs = append(errs, loader.fieldError(field.token, fmt.Sprint(value), err))
+			continue
+		}
+		field.source, field.detail = SourceOverride, ""
+	}
+	return errs
+}
+
+// assign 字符串按 env 规则解码, 其他值需可转换为字段类型
+func (loader *ConfigLoader) assign(token *tagToken, value interface{}) error {
+	if data, ok := value.(string); ok && token.value.Kind() != reflect.String {
+		return loader.decoder.set(token.value, data)
+	}
+	var rv = reflect.ValueOf(value)
+	if !rv.IsValid() {
+		token.value.Set(reflect.Zero(token.value.Type()))
+		return nil
+	}
+	if !rv.Type().ConvertibleTo(token.value.Type()) {
+		return fmt.Errorf("cannot assign %T", value)
+	}
+	token.value.Set(rv.Convert(token.value.Type()))
+	return nil
+}
+
+// checkRequired 全部层加载后 required 字段仍为零值时报错
+func (loader *ConfigLoader) checkRequired() EnvErrors {
+	var errs EnvErrors
+	for _, field := range loader.fields {
+		var token = field.token
+		if token.rules == nil || !token.rules.required || !token.value.IsZero() {
+			continue
+		}
+		errs = append(errs, loader.fieldError(token, "", errEnvRequired))
+	}
+	return errs
+}
+
+func (loader *ConfigLoader) fieldError(token *tagToken, data string, err error) *EnvFieldError {
+	return loader.decoder.fieldError(token, loader.decoder.make(token.Key), data, err)
+}
+
+func (loader *ConfigLoader) field(name string) *configField {
+	for _, v := range loader.fields {
+		if v.token.Field == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Source 字段来源, 未加载或未设置时为 SourceNone
+func (loader *ConfigLoader) Source(field string) ConfigSource {
+	if v := loader.field(field); v != nil {
+		return v.source
+	}
+	return SourceNone
+}
+
+// Sources 全部字段来源
+func (loader *ConfigLoader) Sources() map[string]ConfigSource {
+	var sources = make(map[string]ConfigSource, len(loader.fields))
+	for _, v := range loader.fields {
+		sources[v.token.Field] = v.source
+	}
+	return sources
+}
+
+// Dump 按字段顺序输出 "字段 = 值 ← 来源", eg: Level = "warn" ← env (LEVEL), secret 字段打码
+func (loader *ConfigLoader) Dump() string {
+	var builder strings.Builder
+	for _, v := range loader.fields {
+		var source = string(v.source)
+		if v.source == SourceNone {
+			source = "unset"
+		}
+		if v.detail != "" {
+			source = fmt.Sprintf("%s (%s)", source, v.detail)
+		}
+		var value = dumpValue(v.token.value)
+		if v.token.secret && !v.token.value.IsZero() {
+			value = secretMask
+		}
+		_, _ = fmt.Fprintf(&builder, "%s = %s ← %s\n", v.token.Field, value, source)
+	}
+	return builder.String()
+}
+
+func dumpValue(value *reflect.Value) string {
+	if value.Kind() == reflect.String {
+		return fmt.Sprintf("%q", value.String())
+	}
+	return fmt.Sprintf("%v", value.Interface())
+}
+
+// jsonPathValue 按 json 名称路径取值, 与 json.Unmarshal 一致, 名称不区分大小写
+func jsonPathValue(values map[string]interface{}, path []string) (interface{}, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+	for i, name := range path {
+		var v, ok = jsonLookup(values, name)
+		if !ok {
+			return nil, false
+		}
+		if i == len(path)-1 {
+			return v, true
+		}
+		if values, ok = v.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// jsonText 校验用的原始值: 字符串原样, 其他值为 json 文本
+func jsonText(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	var bytes, _ = json.Marshal(value)
+	return string(bytes)
+}
+
+func jsonLookup(values map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := values[name]; ok {
+		return v, true
+	}
+	for k, v := range values {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type layerConfig struct {
+	Level    string        `json:"level" env:"level,warn"`
+	Name     string        `json:"name" env:"name,app"`
+	Interval time.Duration `json:"interval" env:"interval,1s"`
+	Count    int           `json:"count" env:"count,20,min=1"`
+	Mode     string        `json:"mode" env:"mode,rotate,oneof=rotate|reopen"`
+	Token    string        `json:"token" env:"token,,required"`
+	Data     layerData     `json:"data"`
+}
+
+type layerData struct {
+	Code uint `json:"code" env:"data_code,1"`
+}
+
+func TestConfigLoader_Load(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		file   = filepath.Join(dir, "config.json")
+		config = new(layerConfig)
+	)
+	if err := ioutil.WriteFile(file, []byte(`{"level":"info","name":"file","data":{"code":7}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Setenv("LAYER_NAME", "env")
+	_ = os.Setenv("LAYER_INTERVAL", "5s")
+	defer os.Unsetenv("LAYER_NAME")
+	defer os.Unsetenv("LAYER_INTERVAL")
+	var loader = NewConfigLoader().SetPrefix("layer").SetFile(file).
+		Override("Interval", 10*time.Second).
+		Override("Token", "secret")
+	if err := loader.Load(config); err != nil {
+		t.Fatal(err)
+	}
+	var expects = map[string]ConfigSource{
+		"Level":     SourceFile,
+		"Name":      SourceEnv,
+		"Interval":  SourceOverride,
+		"Count":     SourceDefault,
+		"Token":     SourceOverride,
+		"Data.Code": SourceFile,
+	}
+	for field, source := range expects {
+		if v := loader.Source(field); v != source {
+			t.Errorf("%s source expect %s, got %s", field, source, v)
+		}
+	}
+	if config.Level != "info" || config.Name != "env" || config.Interval != 10*time.Second ||
+		config.Count != 20 || config.Data.Code != 7 {
+		t.Errorf("unexpected config %+v", config)
+	}
+	var dump = loader.Dump()
+	for _, line := range []string{`Level = "info" ← file (` + file + `)`, `Name = "env" ← env (LAYER_NAME)`, `Count = 20 ← default (tag)`} {
+		if !strings.Contains(dump, line) {
+			t.Errorf("dump missing %q:\n%s", line, dump)
+		}
+	}
+}
+
+func TestConfigLoader_Errors(t *testing.T) {
+	var loader = NewConfigLoader().SetPrefix("layer").Override("Count", "many")
+	var errs, ok = loader.Load(new(layerConfig)).(EnvErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expect count and required token errors, got %v", errs)
+	}
+}
+
+func TestConfigLoader_FileRules(t *testing.T) {
+	var loader = NewConfigLoader().SetPrefix("layer").Override("Token", "secret").
+		SetData([]byte(`{"mode":"bogus","count":0,"level":"info"}`))
+	var errs, ok = loader.Load(new(layerConfig)).(EnvErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expect mode and count file errors, got %v", errs)
+	}
+	for _, err := range errs {
+		if err.Key != "data" {
+			t.Errorf("expect file name as key, got %v", err)
+		}
+	}
+	if errs[1].Value != "bogus" || !strings.Contains(errs[1].Error(), "mode") {
+		t.Errorf("unexpected mode error %v", errs[1])
+	}
+}
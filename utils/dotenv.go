@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// dotenv 文件格式:
+//
+//	# 注释
+//	export NAME=value        # 行尾注释 (# 前需有空白)
+//	URL="http://${HOST}/\n"  # 双引号支持 \n \r \t \" \\ \$ 转义, 可跨行
+//	KEY='raw ${value}'       # 单引号原样保留 (${ 转为 $${, 解码时不展开), 可跨行
+//
+// 变量展开 ${NAME} 由 env 解码时处理, 解析时原样保留; 字面量 ${ 写作 $${ 或双引号内 \${;
+// 设置到进程环境变量时 $${ 还原为 ${
+
+type (
+	// EnvLookup 变量查找源, 返回值与是否设置
+	EnvLookup func(key string) (string, bool)
+)
+
+var (
+	dotEnvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+)
+
+// MapLookup 从 map 查找变量, 不读写进程环境变量
+func MapLookup(values map[string]string) EnvLookup {
+	return func(key string) (string, bool) {
+		var v, ok = values[key]
+		return v, ok
+	}
+}
+
+// ChainLookup 依次查找, 返回第一个非空值, eg: ChainLookup(os.LookupEnv, MapLookup(dotenv)) 进程环境变量优先
+func ChainLookup(sources ...EnvLookup) EnvLookup {
+	return func(key string) (string, bool) {
+		for _, source := range sources {
+			if source == nil {
+				continue
+			}
+			if v, ok := source(key); ok && v != "" {
+				return v, true
+			}
+		}
+		return "", false
+	}
+}
+
+// ReadDotEnv 读取 dotenv 文件, 多个文件时后者覆盖前者, 文件不存在时报错
+func ReadDotEnv(names ...string) (map[string]string, error) {
+	if len(names) == 0 {
+		names = append(names, ".env")
+	}
+	var values = make(map[string]string)
+	for _, name := range names {
+		var bytes, err = ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if err = parseDotEnv(string(bytes), values); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return values, nil
+}
+
+// LoadDotEnv 读取 dotenv 文件并设置进程环境变量, 已设置的变量不覆盖
+func LoadDotEnv(names ...string) error {
+	return setDotEnv(false, names...)
+}
+
+// OverloadDotEnv 读取 dotenv 文件并设置进程环境变量, 覆盖已设置的变量
+func OverloadDotEnv(names ...string) error {
+	return setDotEnv(true, names...)
+}
+
+func setDotEnv(override bool, names ...string) error {
+	var values, err = ReadDotEnv(names...)
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		if _, ok := os.LookupEnv(k); ok && !override {
+			continue
+		}
+		if err = os.Setenv(k, unescapeEnv(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unescapeEnv 还原字面量 $${ 为 ${, 仅 env 解码需要转义
+func unescapeEnv(value string) string {
+	return strings.ReplaceAll(value, "$${", "${")
+}
+
+// ParseDotEnv 解析 dotenv 内容
+func ParseDotEnv(reader io.Reader) (map[string]string, error) {
+	var bytes, err = ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var values = make(map[string]string)
+	if err = parseDotEnv(string(bytes), values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func parseDotEnv(data string, values map[string]string) error {
+	var parser = &dotEnvParser{data: strings.ReplaceAll(data, "\r\n", "\n"), line: 1}
+	for {
+		var key, value, ok, err = parser.next()
+		if err != nil {
+			return fmt.Errorf("line %d: %v", parser.line, err)
+		}
+		if !ok {
+			return nil
+		}
+		values[key] = value
+	}
+}
+
+type dotEnvParser struct {
+	data string
+	pos  int
+	line int
+}
+
+// next 解析下一个 KEY=VALUE, 跳过空行与注释
+func (parser *dotEnvParser) next() (string, string, bool, error) {
+	for parser.pos < len(parser.data) {
+		var line = parser.readLine()
+		var trimmed = strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			parser.advance(line)
+			continue
+		}
+		var i = strings.Index(line, "=")
+		if i < 0 {
+			return "", "", false, fmt.Errorf("missing '=' in %q", trimmed)
+		}
+		var key = strings.TrimSpace(line[:i])
+		if strings.HasPrefix(key, "export ") || strings.HasPrefix(key, "export\t") {
+			key = strings.TrimSpace(key[len("export"):])
+		}
+		if !dotEnvKeyPattern.MatchString(key) {
+			return "", "", false, fmt.Errorf("invalid key %q", key)
+		}
+		parser.pos += i + 1
+		var value, err = parser.value()
+		return key, value, err == nil, err
+	}
+	return "", "", false, nil
+}
+
+func (parser *dotEnvParser) readLine() string {
+	var rest = parser.data[parser.pos:]
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		return rest[:i+1]
+	}
+	return rest
+}
+
+func (parser *dotEnvParser) advance(text string) {
+	parser.pos += len(text)
+	parser.line += strings.Count(text, "\n")
+}
+
+// value 解析 = 之后的值, 引号值可跨行
+func (parser *dotEnvParser) value() (string, error) {
+	var line = parser.readLine()
+	var rest = strings.TrimLeft(line, " \t")
+	parser.pos += len(line) - len(rest)
+	if rest == "" {
+		return "", nil
+	}
+	switch rest[0] {
+	case '"', '\'':
+		return parser.quoted(rest[0])
+	}
+	parser.advance(line[len(line)-len(rest):])
+	rest = strings.TrimRight(rest, "\n")
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == '#' && i > 0 && (rest[i-1] == ' ' || rest[i-1] == '\t') {
+			rest = rest[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(rest), nil
+}
+
+// quoted 读取引号值, 双引号处理转义, 结束引号后只允许空白与注释
+func (parser *dotEnvParser) quoted(quote byte) (string, error) {
+	var (
+		builder strings.Builder
+		start   = parser.line
+		i       = parser.pos + 1
+	)
+	for ; i < len(parser.data); i++ {
+		var c = parser.data[i]
+		if c == quote {
+			break
+		}
+		if c == '$' && quote == '\'' && i+1 < len(parser.data) && parser.data[i+1] == '{' {
+			builder.WriteByte('$')
+		}
+		if c == '\\' && quote == '"' && i+1 < len(parser.data) {
+			i++
+			switch next := parser.data[i]; next {
+			case 'n':
+				builder.WriteByte('\n')
+			case 'r':
+				builder.WriteByte('\r')
+			case 't':
+				builder.WriteByte('\t')
+			case '$':
+				// \${ 保留为 $${, 解码时不展开
+				if i+1 < len(parser.data) && parser.data[i+1] == '{' {
+					builder.WriteByte('$')
+				}
+				builder.WriteByte(next)
+			case '"', '\\':
+				builder.WriteByte(next)
+			default:
+				builder.WriteByte('\\')
+				builder.WriteByte(next)
+			}
+			continue
+		}
+		builder.WriteByte(c)
+	}
+	if i >= len(parser.data) {
+		parser.line = start
+		return "", fmt.Errorf("unterminated %c quoted value", quote)
+	}
+	parser.advance(parser.data[parser.pos : i+1])
+	var tail = parser.readLine()
+	if text := strings.TrimSpace(tail); text != "" && !strings.HasPrefix(text, "#") {
+		return "", fmt.Errorf("unexpected %q after quoted value", text)
+	}
+	parser.advance(tail)
+	return builder.String(), nil
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDotEnv = `# local development
+export APP_NAME=demo   # trailing comment
+APP_URL="http://${APP_HOST}:8080/a#b"
+APP_HOST = 127.0.0.1
+APP_RAW='raw $value\n ${APP_HOST}'
+APP_CERT="-----BEGIN-----
+line \"two\"\tend
+-----END-----"
+APP_EMPTY=
+APP_HASH=a#b
+APP_INTERVAL=5s
+APP_LITERAL="\${APP_HOST} $${APP_HOST} \$5"
+`
+
+func TestParseDotEnv(t *testing.T) {
+	var values, err = ParseDotEnv(strings.NewReader(testDotEnv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expects = map[string]string{
+		"APP_NAME":     "demo",
+		"APP_URL":      "http://${APP_HOST}:8080/a#b",
+		"APP_HOST":     "127.0.0.1",
+		"APP_RAW":      `raw $value\n $${APP_HOST}`,
+		"APP_CERT":     "-----BEGIN-----\nline \"two\"\tend\n-----END-----",
+		"APP_EMPTY":    "",
+		"APP_HASH":     "a#b",
+		"APP_INTERVAL": "5s",
+		"APP_LITERAL":  "$${APP_HOST} $${APP_HOST} $5",
+	}
+	if len(values) != len(expects) {
+		t.Errorf("expect %d values, got %v", len(expects), values)
+	}
+	for k, v := range expects {
+		if values[k] != v {
+			t.Errorf("%s expect %q, got %q", k, v, values[k])
+		}
+	}
+	for _, data := range []string{"APP_NAME", "1APP=x", `APP="open`, `APP="x" y`} {
+		if _, err = ParseDotEnv(strings.NewReader(data)); err == nil {
+			t.Errorf("expect error of %q", data)
+		}
+	}
+	if _, err = ParseDotEnv(strings.NewReader("A=1\n\nB='x\ny\nC=3")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expect line 3 error, got %v", err)
+	}
+}
+
+func TestEnvDecoder_Lookup(t *testing.T) {
+	var values, err = ParseDotEnv(strings.NewReader(testDotEnv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env = new(struct {
+		Name     string        `env:"name"`
+		Url      string        `env:"url"`
+		Interval time.Duration `env:"interval"`
+		Literal  string        `env:"literal"`
+		Raw      string        `env:"raw"`
+	})
+	_ = os.Setenv("APP_NAME", "process")
+	defer os.Unsetenv("APP_NAME")
+	var decoder = NewEnvDecoder().SetPrefix("app").SetLookup(MapLookup(values))
+	if err = decoder.Marshal(env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Name != "demo" || env.Url != "http://127.0.0.1:8080/a#b" || env.Interval != 5*time.Second || env.Literal != "${APP_HOST} ${APP_HOST} $5" || env.Raw != `raw $value\n ${APP_HOST}` {
+		t.Errorf("unexpected values %+v", env)
+	}
+	decoder.SetLookup(ChainLookup(os.LookupEnv, MapLookup(values)))
+	if err = decoder.Marshal(env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Name != "process" {
+		t.Errorf("process env should take precedence, got %q", env.Name)
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	var file = filepath.Join(t.TempDir(), ".env")
+	if err := ioutil.WriteFile(file, []byte("DOTENV_A=file\nDOTENV_B=file\nDOTENV_C='${HOME}'\nDOTENV_D=\"\\${HOME}\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Setenv("DOTENV_A", "process")
+	defer os.Unsetenv("DOTENV_A")
+	defer os.Unsetenv("DOTENV_B")
+	defer os.Unsetenv("DOTENV_C")
+	defer os.Unsetenv("DOTENV_D")
+	if err := LoadDotEnv(file); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("DOTENV_A") != "process" || os.Getenv("DOTENV_B") != "file" {
+		t.Errorf("load should keep existing variables")
+	}
+	for _, k := range []string{"DOTENV_C", "DOTENV_D"} {
+		if v := os.Getenv(k); v != "${HOME}" {
+			t.Errorf("%s: process env should hold the unescaped literal, got %q", k, v)
+		}
+	}
+	if err := OverloadDotEnv(file); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("DOTENV_A") != "file" {
+		t.Errorf("overload should replace existing variables")
+	}
+}
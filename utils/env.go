@@ -1,17 +1,25 @@
 package utils
 
 import (
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
 )
 
+var (
+	envRefPattern       = regexp.MustCompile(`\$\$\{|\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
 type (
 	CaseMode int
 
@@ -20,17 +28,30 @@ type (
 		prefix   string
 		suffix   string
 		tag      string
+		source   EnvLookup
 	}
 
 	tagToken struct {
 		Key     string
 		Default string
+		// Field 字段路径, eg: Data.Info.ID; json/yaml 为对应的名称路径, 忽略 json/yaml 的字段为空
+		Field   string
+		json    []string
+		yaml    []string
+		desc    string
+		secret  bool
+		options []string
 		value   *reflect.Value
+		rules   *envRules
+		err     error
 	}
 )
 
 const (
 	envTag                  = "env"
+	envFileSuffix           = "_FILE"
+	descTag                 = "desc"
+	secretTag               = "secret"
 	UnDefineCase   CaseMode = 0
 	UpperCase      CaseMode = 1
 	LowerCase      CaseMode = 2
@@ -58,6 +79,12 @@ func (decoder *envTagDecoder) SetSuffix(suffix string) *envTagDecoder {
 	return decoder
 }
 
+// SetLookup 设置变量查找源, eg: MapLookup(values), 为 nil 时读取进程环境变量
+func (decoder *envTagDecoder) SetLookup(source EnvLookup) *envTagDecoder {
+	decoder.source = source
+	return decoder
+}
+
 func (decoder *envTagDecoder) GetPrefix() string {
 	return decoder.prefix
 }
@@ -118,152 +145,179 @@ func (decoder *envTagDecoder) parse(v interface{}, valueAddr ...reflect.Value) [
 	return tokens
 }
 
+// load 按标签加载全部字段, 返回所有字段的解析/校验错误 (EnvErrors)
 func (decoder *envTagDecoder) load(tokens []*tagToken) error {
-	var err error
+	var errs EnvErrors
+	for _, v := range tokens {
+		if v == nil || v.Key == "" || v.value == nil {
+			continue
+		}
+		var name, data, err = decoder.lookup(v.Key)
+		if err == nil {
+			if data == "" {
+				data = decoder.expand(v.Default)
+			}
+			err = decoder.decode(v, data)
+		}
+		if err != nil {
+			errs = append(errs, decoder.fieldError(v, name, data, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// fieldError 字段错误, 文件读取的密钥不输出到错误中, 以文件路径代替
+func (decoder *envTagDecoder) fieldError(token *tagToken, name, data string, err error) *EnvFieldError {
+	if strings.HasSuffix(name, envFileSuffix) {
+		data = decoder.getenv(name)
+	}
+	return &EnvFieldError{
+		Key:   name,
+		Value: data,
+		Type:  token.value.Type().String(),
+		Err:   err,
+	}
+}
+
+// decode 解码并校验单个字段, 空值不解码 (保留原值), 标记 required 时报错
+func (decoder *envTagDecoder) decode(token *tagToken, data string) (err error) {
 	defer func() {
 		if v := recover(); v != nil {
-			err = v.(error)
+			err = fmt.Errorf("%v", v)
 		}
 	}()
-	for _, v := range tokens {
-		if v == nil || v.Key == "" || v.value == nil {
-			continue
+	if token.err != nil {
+		return token.err
+	}
+	if data == "" {
+		if token.rules != nil && token.rules.required {
+			return errEnvRequired
 		}
-		decoder.set(v.value, decoder.GetEnvOr(v.Key, v.Default))
+		return nil
+	}
+	if err = decoder.set(token.value, data); err != nil {
+		return err
 	}
-	return err
+	return token.rules.check(token.value, data)
 }
 
-func (decoder *envTagDecoder) set(value *reflect.Value, data string) {
-	if value == nil {
-		return
+func (decoder *envTagDecoder) set(value *reflect.Value, data string) error {
+	if value == nil || !value.CanSet() {
+		return nil
 	}
-	if !value.CanSet() {
-		return
+	// 注册的自定义类型
+	if ok, err := envTypes.decode(value, data); ok {
+		return err
+	}
+	// 指针分配新值后解码, 失败时保留原值
+	if value.Kind() == reflect.Ptr {
+		var elem = reflect.New(value.Type().Elem()).Elem()
+		if err := decoder.set(&elem, data); err != nil {
+			return err
+		}
+		value.Set(elem.Addr())
+		return nil
 	}
-	var valueAny = value.Interface()
 	// 时间类型处理
-	switch valueAny.(type) {
+	switch value.Interface().(type) {
 	case time.Time:
 		if d, err := time.Parse(time.RFC3339, data); err == nil {
 			value.Set(reflect.ValueOf(d))
-			return
+			return nil
 		}
-		if d, err := time.Parse(DateTimeLayout, data); err == nil {
-			value.Set(reflect.ValueOf(d))
-			return
+		var d, err = time.Parse(DateTimeLayout, data)
+		if err != nil {
+			return fmt.Errorf("expect RFC3339 or %q time", DateTimeLayout)
 		}
+		value.Set(reflect.ValueOf(d))
+		return nil
 	case time.Duration:
-		if d, err := time.ParseDuration(data); err == nil {
-			value.Set(reflect.ValueOf(d))
-			return
+		var d, err = time.ParseDuration(data)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+	// 实现 encoding.TextUnmarshaler 的类型, eg: net.IP, logrus.Level
+	if value.CanAddr() {
+		if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(data))
 		}
 	}
 	// 基础类型映射解码
-	var kind = value.Kind()
-	switch kind {
+	switch kind := value.Kind(); kind {
 	case reflect.Bool:
-		if b, err := strconv.ParseBool(data); err == nil {
-			value.Set(reflect.ValueOf(b))
-		}
-	case reflect.Int:
-		if n, err := strconv.Atoi(data); err == nil {
-			value.Set(reflect.ValueOf(n))
-		}
-	case reflect.Int8:
-		if n, err := strconv.Atoi(data); err == nil {
-			value.Set(reflect.ValueOf(int8(n)))
-		}
-	case reflect.Int16:
-		if n, err := strconv.Atoi(data); err == nil {
-			value.Set(reflect.ValueOf(int16(n)))
-		}
-	case reflect.Int32:
-		if n, err := strconv.Atoi(data); err == nil {
-			value.Set(reflect.ValueOf(int32(n)))
-		}
-	case reflect.Int64:
-		if n, err := strconv.Atoi(data); err == nil {
-			value.Set(reflect.ValueOf(int64(n)))
-		}
-	case reflect.Uint:
-		if n, err := strconv.ParseUint(data, 10, 64); err == nil {
-			value.Set(reflect.ValueOf(uint(n)))
-		}
-	case reflect.Uint8:
-		if n, err := strconv.ParseUint(data, 10, 64); err == nil {
-			value.Set(reflect.ValueOf(uint8(n)))
-		}
-	case reflect.Uint16:
-		if n, err := strconv.ParseUint(data, 10, 64); err == nil {
-			value.Set(reflect.ValueOf(uint16(n)))
-		}
-	case reflect.Uint32:
-		if n, err := strconv.ParseUint(data, 10, 64); err == nil {
-			value.Set(reflect.ValueOf(uint32(n)))
-		}
-	case reflect.Uint64:
-		if n, err := strconv.ParseUint(data, 10, 64); err == nil {
-			value.Set(reflect.ValueOf(n))
-		}
-	case reflect.Float32:
-		if n, err := strconv.ParseFloat(data, 64); err == nil {
-			value.Set(reflect.ValueOf(float32(n)))
-		}
-	case reflect.Float64:
-		if n, err := strconv.ParseFloat(data, 64); err == nil {
-			value.Set(reflect.ValueOf(n))
-		}
-	case reflect.Complex64:
-		if n, err := strconv.ParseComplex(data, 64); err == nil {
-			value.Set(reflect.ValueOf(complex64(n)))
-		}
-	case reflect.Complex128:
-		if n, err := strconv.ParseComplex(data, 128); err == nil {
-			value.Set(reflect.ValueOf(n))
-		}
-	case reflect.Map:
-		var bytes = []byte(data)
-		if err := decoder.bytesJsonDecoder(bytes, value.Addr().Interface(), true); err != nil {
-			return
-		}
-	case reflect.Array:
-		var bytes, ok = decoder.arrBytesDecoder(data)
-		if !ok {
-			return
-		}
-		if err := decoder.bytesJsonDecoder(bytes, value.Addr().Interface()); err != nil {
-			return
-		}
-	case reflect.Slice:
-		var bytes, ok = decoder.arrBytesDecoder(data)
+		var b, err = strconv.ParseBool(data)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n, err = strconv.ParseInt(data, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n, err = strconv.ParseUint(data, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var n, err = strconv.ParseFloat(data, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(n)
+	case reflect.Complex64, reflect.Complex128:
+		var n, err = strconv.ParseComplex(data, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetComplex(n)
+	case reflect.Map, reflect.Struct:
+		return decoder.bytesJsonDecoder([]byte(data), value.Addr().Interface(), true)
+	case reflect.Array, reflect.Slice:
+		var (
+			elem      = value.Type().Elem()
+			bytes, ok = decoder.arrBytesDecoder(data, elem.Kind() == reflect.String || reflect.PtrTo(elem).Implements(textUnmarshalerType))
+		)
 		if !ok {
-			return
-		}
-		if err := decoder.bytesJsonDecoder(bytes, value.Addr().Interface()); err != nil {
-			return
+			return errors.New("expect json array or comma separated list")
 		}
+		return decoder.bytesJsonDecoder(bytes, value.Addr().Interface())
 	case reflect.String:
-		value.Set(reflect.ValueOf(data))
-	case reflect.Struct:
-		var bytes = []byte(data)
-		if err := decoder.bytesJsonDecoder(bytes, value.Addr().Interface(), true); err != nil {
-			return
-		}
+		value.SetString(data)
+	default:
+		return fmt.Errorf("unsupported kind %s", kind)
 	}
-	return
+	return nil
 }
 
 func (decoder *envTagDecoder) bytesJsonDecoder(bytes []byte, addr interface{}, check ...bool) error {
 	check = append(check, false)
 	if check[0] && !json.Valid(bytes) {
-		return errors.New("bytes json valid failed")
+		return errors.New("invalid json")
 	}
 	return json.Unmarshal(bytes, addr)
 }
 
-func (decoder *envTagDecoder) arrBytesDecoder(data string) ([]byte, bool) {
+// arrBytesDecoder 非 json 数组按逗号分隔, 文本元素 (字符串或 TextUnmarshaler) 逐个转义, eg: warn,error
+func (decoder *envTagDecoder) arrBytesDecoder(data string, text bool) ([]byte, bool) {
 	var bytes = []byte(data)
+	if !json.Valid(bytes) && text {
+		var items = strings.Split(data, ",")
+		for i, v := range items {
+			items[i] = strings.TrimSpace(v)
+		}
+		bytes, _ = json.Marshal(items)
+		return bytes, true
+	}
 	if !json.Valid(bytes) {
 		if strings.Contains(data, "{") || strings.Contains(data, "}") {
 			return nil, false
@@ -281,20 +335,68 @@ func (decoder *envTagDecoder) arrBytesDecoder(data string) ([]byte, bool) {
 }
 
 func (decoder *envTagDecoder) GetEnvOr(key string, def ...string) string {
-	var k = decoder.make(key)
-	if v := os.Getenv(k); v != "" {
+	if _, v, err := decoder.lookup(key); err == nil && v != "" {
 		return v
 	}
 	if len(def) > 0 {
-		return def[0]
+		return decoder.expand(def[0])
 	}
 	return ""
 }
 
+// lookup 读取环境变量, 未设置时读取 <KEY>_FILE 指向的文件 (去掉末尾换行, 不展开变量);
+// 变量值中的 ${OTHER_VAR} 展开为对应环境变量, 返回实际读取的变量名
+func (decoder *envTagDecoder) lookup(key string) (string, string, error) {
+	var (
+		name = decoder.make(key)
+		file = decoder.getenv(name + envFileSuffix)
+	)
+	if v := decoder.getenv(name); v != "" {
+		if file != "" {
+			return name, v, fmt.Errorf("both %s and %s%s are set", name, name, envFileSuffix)
+		}
+		return name, decoder.expand(v), nil
+	}
+	if file == "" {
+		return name, "", nil
+	}
+	name += envFileSuffix
+	var bytes, err = ioutil.ReadFile(file)
+	if err != nil {
+		return name, file, err
+	}
+	var value = strings.TrimSuffix(string(bytes), "\n")
+	return name, strings.TrimSuffix(value, "\r"), nil
+}
+
+// expand 展开 ${NAME}, 未设置的变量展开为空, $${ 转义为字面量 ${; 不处理 $NAME 形式以保留值中的 $
+func (decoder *envTagDecoder) expand(value string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	return envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		return decoder.getenv(ref[2 : len(ref)-1])
+	})
+}
+
+// getenv 从查找源读取变量, 默认为进程环境变量
+func (decoder *envTagDecoder) getenv(key string) string {
+	if decoder.source == nil {
+		return os.Getenv(key)
+	}
+	var v, _ = decoder.source(key)
+	return v
+}
+
 func (decoder *envTagDecoder) DebugKey(key string) string {
 	return decoder.make(key)
 }
 
+// make 生成变量名; 前缀连接沿用原有规则 (按后缀是否以 _ 开头决定), eg: 前缀 app_ => APP__LOG_NAME,
+// 修改会改变已部署环境的变量名
 func (decoder *envTagDecoder) make(key string) string {
 	if key == "" {
 		return ""
@@ -334,6 +436,7 @@ func (decoder *envTagDecoder) make(key string) string {
 func (decoder *envTagDecoder) values(field reflect.StructField, value reflect.Value) []*tagToken {
 	var (
 		key, defaultValue string
+		options           []string
 		tokenArr          []*tagToken
 		token             = new(tagToken)
 		kind              = field.Type.Kind()
@@ -346,34 +449,79 @@ func (decoder *envTagDecoder) values(field reflect.StructField, value reflect.Va
 	if kind == reflect.Struct && value.CanAddr() && !anonymous {
 		tokenArr = decoder.parse(value.Interface(), value)
 		if len(tokenArr) > 0 {
-			return tokenArr
+			return nestTokens(field, tokenArr)
 		}
 	}
 	// 解析
 	if kind == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct && !anonymous {
 		tokenArr = decoder.parse(value.Interface())
 		if len(tokenArr) > 0 {
-			return tokenArr
+			return nestTokens(field, tokenArr)
 		}
 	}
-	key, defaultValue = decoder.getTagInfo(field.Tag.Get(decoder.tag))
+	key, defaultValue, options = decoder.getTagInfo(field.Tag.Get(decoder.tag))
 	if key == "" {
 		return nil
 	}
 	token.Key = key
 	token.Default = defaultValue
+	token.Field = field.Name
+	token.desc = field.Tag.Get(descTag)
+	token.secret, _ = strconv.ParseBool(field.Tag.Get(secretTag))
+	token.options = options
+	if name := tagName(field, "json"); name != "" {
+		token.json = []string{name}
+	}
+	if name := tagName(field, "yaml"); name != "" {
+		token.yaml = []string{name}
+	}
+	token.rules, token.err = parseRules(options)
 	token.value = &value
 	tokenArr = append(tokenArr, token)
 	return tokenArr
 }
 
-func (decoder *envTagDecoder) getTagInfo(tag string) (Key string, Default string) {
+// nestTokens 嵌套结构体字段路径加上父字段前缀
+func nestTokens(field reflect.StructField, tokens []*tagToken) []*tagToken {
+	var (
+		jsonParent = tagName(field, "json")
+		yamlParent = tagName(field, "yaml")
+	)
+	for _, v := range tokens {
+		v.Field = field.Name + "." + v.Field
+		v.json = nestPath(jsonParent, v.json)
+		v.yaml = nestPath(yamlParent, v.yaml)
+	}
+	return tokens
+}
+
+func nestPath(parent string, path []string) []string {
+	if parent == "" || len(path) == 0 {
+		return nil
+	}
+	return append([]string{parent}, path...)
+}
+
+// tagName 字段 json/yaml 名称, 忽略 (-) 时为空
+func tagName(field reflect.StructField, tag string) string {
+	var name = strings.Split(field.Tag.Get(tag), ",")[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// getTagInfo 解析标签: 变量名, 默认值, 校验选项
+func (decoder *envTagDecoder) getTagInfo(tag string) (Key string, Default string, Options []string) {
 	if tag == "" {
-		return "", ""
+		return "", "", nil
 	}
 	var pos = strings.Split(tag, ",")
 	if len(pos) >= 2 {
-		return strings.TrimSpace(pos[0]), strings.TrimSpace(pos[1])
+		return strings.TrimSpace(pos[0]), strings.TrimSpace(pos[1]), pos[2:]
 	}
-	return strings.TrimSpace(tag), ""
+	return strings.TrimSpace(tag), "", nil
 }
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 环境变量文档格式
+const (
+	DocMarkdown = "markdown"
+	DocDotEnv   = "env"
+	DocJson     = "json"
+)
+
+type (
+	// EnvDoc 单个环境变量说明, Key 为加上前缀/后缀并按 CaseMode 转换后的变量名
+	EnvDoc struct {
+		Key     string `json:"key"`
+		Field   string `json:"field"`
+		Type    string `json:"type"`
+		Default string `json:"default"`
+		Json    string `json:"json"`
+		Yaml    string `json:"yaml"`
+		Rules   string `json:"rules"`
+		Desc    string `json:"desc"`
+	}
+)
+
+// Describe 按 env 标签列出 v 的全部环境变量, 字段说明读取 desc 标签
+func (decoder *envTagDecoder) Describe(v interface{}) ([]*EnvDoc, error) {
+	var tokens = decoder.parse(v)
+	if len(tokens) <= 0 {
+		return nil, errors.New("tag parse failed")
+	}
+	var docs = make([]*EnvDoc, 0, len(tokens))
+	for _, token := range tokens {
+		if token == nil || token.Key == "" || token.value == nil {
+			continue
+		}
+		var rules = make([]string, 0, len(token.options))
+		for _, option := range token.options {
+			if option = strings.TrimSpace(option); option != "" {
+				rules = append(rules, option)
+			}
+		}
+		docs = append(docs, &EnvDoc{
+			Key:     decoder.make(token.Key),
+			Field:   token.Field,
+			Type:    token.value.Type().String(),
+			Default: token.Default,
+			Json:    strings.Join(token.json, "."),
+			Yaml:    strings.Join(token.yaml, "."),
+			Rules:   strings.Join(rules, ","),
+			Desc:    token.desc,
+		})
+	}
+	return docs, nil
+}
+
+// WriteEnvDocs 输出环境变量文档, format: markdown 表格, env (.env 示例) 或 json
+func WriteEnvDocs(w io.Writer, docs []*EnvDoc, format string) error {
+	switch strings.ToLower(format) {
+	case "", DocMarkdown, "md":
+		return writeMarkdownDocs(w, docs)
+	case DocDotEnv, ".env", "dotenv":
+		return writeDotEnvDocs(w, docs)
+	case DocJson:
+		var encoder = json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(docs)
+	}
+	return fmt.Errorf("unknown doc format %q, expect %s, %s or %s", format, DocMarkdown, DocDotEnv, DocJson)
+}
+
+func writeMarkdownDocs(w io.Writer, docs []*EnvDoc) error {
+	var builder strings.Builder
+	builder.WriteString("| Env | Type | Default | JSON | YAML | Rules | Description |\n")
+	builder.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, v := range docs {
+		_, _ = fmt.Fprintf(&builder, "| `%s` | %s | %s | %s | %s | %s | %s |\n",
+			v.Key, markdownCell(v.Type), markdownCode(v.Default), markdownCell(v.Json),
+			markdownCell(v.Yaml), markdownCode(v.Rules), markdownCell(v.Desc))
+	}
+	var _, err = io.WriteString(w, builder.String())
+	return err
+}
+
+// writeDotEnvDocs 每个变量一行, 说明/类型/规则写入上方注释, 值为默认值
+func writeDotEnvDocs(w io.Writer, docs []*EnvDoc) error {
+	var builder strings.Builder
+	for i, v := range docs {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		if v.Desc != "" {
+			_, _ = fmt.Fprintf(&builder, "# %s\n", strings.ReplaceAll(v.Desc, "\n", "\n# "))
+		}
+		var comment = v.Type
+		if v.Rules != "" {
+			comment += ", " + v.Rules
+		}
+		_, _ = fmt.Fprintf(&builder, "# %s (%s)\n%s=%s\n", v.Field, comment, v.Key, dotEnvValue(v.Default))
+	}
+	var _, err = io.WriteString(w, builder.String())
+	return err
+}
+
+func dotEnvValue(value string) string {
+	switch {
+	case value == "" || !strings.ContainsAny(value, " #\"'$"):
+		return value
+	case strings.Contains(value, "'"):
+		return strconv.Quote(value)
+	}
+	return "'" + value + "'"
+}
+
+func markdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.ReplaceAll(value, "\n", "<br>")
+}
+
+func markdownCode(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "`" + markdownCell(value) + "`"
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type docConfig struct {
+	Mode  string    `json:"mode" yaml:"run_mode" env:"mode,rotate,oneof=rotate|reopen" desc:"运行模式"`
+	Token string    `json:"-" env:"token,,required" desc:"访问令牌"`
+	Data  docNested `json:"data" yaml:"data"`
+}
+
+type docNested struct {
+	Code int `json:"code" yaml:"code" env:"code,1"`
+}
+
+func TestEnvDecoder_Describe(t *testing.T) {
+	var docs, err = NewEnvDecoder(UpperCase).SetPrefix("app").Describe(new(docConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("expect 3 docs, got %d", len(docs))
+	}
+	var mode = docs[0]
+	if mode.Key != "APP_MODE" || mode.Yaml != "run_mode" || mode.Rules != "oneof=rotate|reopen" || mode.Desc != "运行模式" {
+		t.Errorf("unexpected doc %+v", mode)
+	}
+	if docs[1].Json != "" || docs[1].Rules != "required" {
+		t.Errorf("unexpected doc %+v", docs[1])
+	}
+	if docs[2].Key != "APP_CODE" || docs[2].Field != "Data.Code" || docs[2].Json != "data.code" {
+		t.Errorf("unexpected doc %+v", docs[2])
+	}
+
+	var buffer bytes.Buffer
+	if err = WriteEnvDocs(&buffer, docs, DocMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), "| `APP_MODE` | string | `rotate` | mode | run_mode | `oneof=rotate\\|reopen` | 运行模式 |") {
+		t.Errorf("unexpected markdown:\n%s", buffer.String())
+	}
+	buffer.Reset()
+	if err = WriteEnvDocs(&buffer, docs, DocDotEnv); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), "# 访问令牌\n# Token (string, required)\nAPP_TOKEN=\n") {
+		t.Errorf("unexpected dotenv:\n%s", buffer.String())
+	}
+	buffer.Reset()
+	if err = WriteEnvDocs(&buffer, docs, DocJson); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []*EnvDoc
+	if err = json.Unmarshal(buffer.Bytes(), &decoded); err != nil || len(decoded) != 3 {
+		t.Errorf("unexpected json docs %v: %s", err, buffer.String())
+	}
+	if err = WriteEnvDocs(&buffer, docs, "xml"); err == nil {
+		t.Error("expect unknown format error")
+	}
+}
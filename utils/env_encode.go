@@ -0,0 +1,441 @@
+package utils
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 配置导出格式, env 为 KEY=value, k8s 为 Kubernetes 容器 env 列表
+const (
+	EncodeEnv        = "env"
+	EncodeJson       = "json"
+	EncodeYaml       = "yaml"
+	EncodeKubernetes = "k8s"
+
+	secretMask = "******"
+)
+
+type (
+	// envTagEncoder 按 env 标签导出配置, 变量名规则与 envTagDecoder 一致, secret 标签字段默认打码
+	envTagEncoder struct {
+		decoder    *envTagDecoder
+		showSecret bool
+	}
+
+	// EnvPair 导出的变量, Value 与 env 解码格式一致
+	EnvPair struct {
+		Key    string
+		Value  string
+		Secret bool
+	}
+
+	// encodeNode json/yaml 按字段顺序输出的节点, 叶子节点 value 有效
+	encodeNode struct {
+		name     string
+		value    *reflect.Value
+		masked   bool
+		children []*encodeNode
+	}
+)
+
+func NewEnvEncoder(caseMode ...CaseMode) *envTagEncoder {
+	var encoder = new(envTagEncoder)
+	encoder.decoder = NewEnvDecoder(caseMode...)
+	return encoder
+}
+
+func (encoder *envTagEncoder) SetPrefix(prefix string) *envTagEncoder {
+	encoder.decoder.SetPrefix(prefix)
+	return encoder
+}
+
+func (encoder *envTagEncoder) SetSuffix(suffix string) *envTagEncoder {
+	encoder.decoder.SetSuffix(suffix)
+	return encoder
+}
+
+// ShowSecret 输出 secret 字段原值, 默认打码
+func (encoder *envTagEncoder) ShowSecret(show bool) *envTagEncoder {
+	encoder.showSecret = show
+	return encoder
+}
+
+// Encode 导出全部 env 标签字段
+func (encoder *envTagEncoder) Encode(v interface{}) ([]*EnvPair, error) {
+	var tokens, err = encoder.tokens(v)
+	if err != nil {
+		return nil, err
+	}
+	var pairs = make([]*EnvPair, 0, len(tokens))
+	for _, token := range tokens {
+		var value, err = encodeValue(*token.value)
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %v", token.Field, err)
+		}
+		if encoder.masked(token, value) {
+			value = secretMask
+		}
+		// 字面量 ${ 转义, 解码时不展开
+		value = strings.ReplaceAll(value, "${", "$${")
+		pairs = append(pairs, &EnvPair{Key: encoder.decoder.make(token.Key), Value: value, Secret: token.secret})
+	}
+	return pairs, nil
+}
+
+// Write 按格式输出: env, json, yaml 或 k8s
+func (encoder *envTagEncoder) Write(w io.Writer, v interface{}, format string) error {
+	var (
+		buffer bytes.Buffer
+		err    error
+	)
+	switch strings.ToLower(format) {
+	case "", EncodeEnv, ".env", "dotenv":
+		err = encoder.writeEnv(&buffer, v)
+	case EncodeKubernetes, "kubernetes":
+		err = encoder.writeKubernetes(&buffer, v)
+	case EncodeJson:
+		err = encoder.writeJson(&buffer, v)
+	case EncodeYaml, "yml":
+		err = encoder.writeYaml(&buffer, v)
+	default:
+		err = fmt.Errorf("unknown encode format %q, expect %s, %s, %s or %s", format, EncodeEnv, EncodeJson, EncodeYaml, EncodeKubernetes)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buffer.Bytes())
+	return err
+}
+
+func (encoder *envTagEncoder) tokens(v interface{}) ([]*tagToken, error) {
+	var (
+		tokens = encoder.decoder.parse(v)
+		result = make([]*tagToken, 0, len(tokens))
+	)
+	for _, token := range tokens {
+		if token == nil || token.Key == "" || token.value == nil {
+			continue
+		}
+		result = append(result, token)
+	}
+	if len(result) <= 0 {
+		return nil, errors.New("tag parse failed")
+	}
+	return result, nil
+}
+
+// masked 空值不打码, 便于区分未设置
+func (encoder *envTagEncoder) masked(token *tagToken, value string) bool {
+	return token.secret && !encoder.showSecret && value != ""
+}
+
+func (encoder *envTagEncoder) writeEnv(w io.Writer, v interface{}) error {
+	var pairs, err = encoder.Encode(v)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		if _, err = fmt.Fprintf(w, "%s=%s\n", pair.Key, dotEnvValue(pair.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (encoder *envTagEncoder) writeKubernetes(w io.Writer, v interface{}) error {
+	var pairs, err = encoder.Encode(v)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		if _, err = fmt.Fprintf(w, "- name: %s\n  value: %s\n", pair.Key, strconv.Quote(pair.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tree 按 json/yaml 名称路径组织字段, 忽略 json/yaml 的字段不输出
+func (encoder *envTagEncoder) tree(v interface{}, yaml bool) (*encodeNode, error) {
+	var tokens, err = encoder.tokens(v)
+	if err != nil {
+		return nil, err
+	}
+	var root = new(encodeNode)
+	for _, token := range tokens {
+		var path = token.json
+		if yaml {
+			path = token.yaml
+		}
+		if len(path) == 0 {
+			continue
+		}
+		var node = root
+		for _, name := range path {
+			node = node.child(name)
+		}
+		node.value = token.value
+		if token.secret && !encoder.showSecret {
+			var text, _ = encodeValue(*token.value)
+			node.masked = text != ""
+		}
+	}
+	return root, nil
+}
+
+func (node *encodeNode) child(name string) *encodeNode {
+	for _, v := range node.children {
+		if v.name == name {
+			return v
+		}
+	}
+	var child = &encodeNode{name: name}
+	node.children = append(node.children, child)
+	return child
+}
+
+func (encoder *envTagEncoder) writeJson(w *bytes.Buffer, v interface{}) error {
+	var root, err = encoder.tree(v, false)
+	if err != nil {
+		return err
+	}
+	var compact bytes.Buffer
+	if err = root.writeJson(&compact); err != nil {
+		return err
+	}
+	if err = json.Indent(w, compact.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	w.WriteByte('\n')
+	return nil
+}
+
+func (node *encodeNode) writeJson(w *bytes.Buffer) error {
+	if node.value != nil {
+		var value interface{} = node.value.Interface()
+		if node.masked {
+			value = secretMask
+		}
+		var data, err = json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("encode %s: %v", node.name, err)
+		}
+		w.Write(data)
+		return nil
+	}
+	w.WriteByte('{')
+	for i, child := range node.children {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		var name, _ = json.Marshal(child.name)
+		w.Write(name)
+		w.WriteByte(':')
+		if err := child.writeJson(w); err != nil {
+			return err
+		}
+	}
+	w.WriteByte('}')
+	return nil
+}
+
+func (encoder *envTagEncoder) writeYaml(w *bytes.Buffer, v interface{}) error {
+	var root, err = encoder.tree(v, true)
+	if err != nil {
+		return err
+	}
+	for _, child := range root.children {
+		if err = child.writeYaml(w, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeYaml 手写 yaml 输出: 结构体/map 为块映射, 切片为块序列, 标量按需加双引号
+func (node *encodeNode) writeYaml(w *bytes.Buffer, depth int) error {
+	var indent = strings.Repeat("  ", depth)
+	if node.value == nil {
+		fmt.Fprintf(w, "%s%s:\n", indent, yamlKey(node.name))
+		for _, child := range node.children {
+			if err := child.writeYaml(w, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if node.masked {
+		fmt.Fprintf(w, "%s%s: %q\n", indent, yamlKey(node.name), secretMask)
+		return nil
+	}
+	var value = indirect(*node.value)
+	if !value.IsValid() {
+		fmt.Fprintf(w, "%s%s: null\n", indent, yamlKey(node.name))
+		return nil
+	}
+	if _, ok := value.Interface().(encoding.TextMarshaler); !ok {
+		switch value.Kind() {
+		case reflect.Slice, reflect.Array:
+			if value.Len() == 0 {
+				fmt.Fprintf(w, "%s%s: []\n", indent, yamlKey(node.name))
+				return nil
+			}
+			fmt.Fprintf(w, "%s%s:\n", indent, yamlKey(node.name))
+			for i := 0; i < value.Len(); i++ {
+				var text, err = yamlScalar(value.Index(i))
+				if err != nil {
+					return fmt.Errorf("encode %s: %v", node.name, err)
+				}
+				fmt.Fprintf(w, "%s  - %s\n", indent, text)
+			}
+			return nil
+		case reflect.Map:
+			if value.Len() == 0 {
+				fmt.Fprintf(w, "%s%s: {}\n", indent, yamlKey(node.name))
+				return nil
+			}
+			fmt.Fprintf(w, "%s%s:\n", indent, yamlKey(node.name))
+			var keys = value.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+			})
+			for _, key := range keys {
+				var text, err = yamlScalar(value.MapIndex(key))
+				if err != nil {
+					return fmt.Errorf("encode %s: %v", node.name, err)
+				}
+				fmt.Fprintf(w, "%s  %s: %s\n", indent, yamlKey(fmt.Sprint(key.Interface())), text)
+			}
+			return nil
+		}
+	}
+	var text, err = yamlScalar(value)
+	if err != nil {
+		return fmt.Errorf("encode %s: %v", node.name, err)
+	}
+	fmt.Fprintf(w, "%s%s: %s\n", indent, yamlKey(node.name), text)
+	return nil
+}
+
+// yamlScalar 标量按 env 格式输出, 字符串与复杂值按需转为双引号 (json 兼容) 字符串
+func yamlScalar(value reflect.Value) (string, error) {
+	value = indirect(value)
+	if !value.IsValid() {
+		return "null", nil
+	}
+	var text, err = encodeValue(value)
+	if err != nil {
+		return "", err
+	}
+	switch value.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		if _, ok := value.Interface().(time.Duration); !ok {
+			return text, nil
+		}
+	}
+	if yamlPlain(text) {
+		return text, nil
+	}
+	return strconv.Quote(text), nil
+}
+
+// yamlPlain 无需加引号的字符串: 非空, 不会被解析为 bool/null/数字, 不含特殊字符
+func yamlPlain(text string) bool {
+	if text == "" || strings.TrimSpace(text) != text {
+		return false
+	}
+	switch strings.ToLower(text) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", "y", "n":
+		return false
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return false
+	}
+	if strings.ContainsAny(text, ":#{}[],&*!|>'\"%@`\n\t\\") || strings.HasPrefix(text, "-") || strings.HasPrefix(text, "?") {
+		return false
+	}
+	return true
+}
+
+func yamlKey(name string) string {
+	if yamlPlain(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+// encodeValue 与 envTagDecoder.set 对应的文本格式, 字符串切片以逗号分隔, 其他复杂类型为 json
+func encodeValue(value reflect.Value) (string, error) {
+	value = indirect(value)
+	if !value.IsValid() {
+		return "", nil
+	}
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return "", nil
+		}
+		return v.Format(time.RFC3339), nil
+	case time.Duration:
+		return v.String(), nil
+	case url.URL:
+		return v.String(), nil
+	case encoding.TextMarshaler:
+		var text, err = v.MarshalText()
+		return string(text), err
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits()), nil
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(value.Complex(), 'g', -1, value.Type().Bits()), nil
+	case reflect.Slice, reflect.Array:
+		if value.Len() == 0 {
+			return "", nil
+		}
+		if value.Type().Elem().Kind() == reflect.String {
+			var items = make([]string, value.Len())
+			for i := range items {
+				items[i] = value.Index(i).String()
+			}
+			if !strings.Contains(strings.Join(items, ""), ",") {
+				return strings.Join(items, ","), nil
+			}
+		}
+	case reflect.Map:
+		if value.Len() == 0 {
+			return "", nil
+		}
+	}
+	var data, err = json.Marshal(value.Interface())
+	return string(data), err
+}
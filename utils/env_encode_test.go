@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type encodeConfig struct {
+	Name     string            `json:"name" yaml:"name" env:"name,app"`
+	Layout   string            `json:"layout" yaml:"layout" env:"layout,%s-%Y%m%d.log"`
+	Interval time.Duration     `json:"interval" yaml:"interval" env:"interval,24h"`
+	Levels   []string          `json:"levels" yaml:"levels" env:"levels,warn"`
+	Ages     map[string]string `json:"ages" yaml:"ages" env:"ages"`
+	Count    uint              `json:"count" yaml:"count" env:"count,20"`
+	Token    string            `json:"token" yaml:"token" env:"token" secret:"true"`
+	Data     encodeNested      `json:"data" yaml:"data"`
+}
+
+type encodeNested struct {
+	Ratio float64 `json:"ratio" yaml:"ratio" env:"ratio,0.5"`
+	On    bool    `json:"on" yaml:"on" env:"on,true"`
+}
+
+func TestEnvEncoder_Encode(t *testing.T) {
+	var config = &encodeConfig{
+		Name:     "demo app",
+		Layout:   "%s-${env}.log",
+		Interval: 90 * time.Minute,
+		Levels:   []string{"warn", "error"},
+		Ages:     map[string]string{"error": "90d"},
+		Count:    3,
+		Token:    "s3cr3t",
+		Data:     encodeNested{Ratio: 0.25, On: true},
+	}
+	var encoder = NewEnvEncoder(UpperCase).SetPrefix("app")
+	var pairs, err = encoder.ShowSecret(true).Encode(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values = make(map[string]string)
+	for _, pair := range pairs {
+		values[pair.Key] = pair.Value
+	}
+	if values["APP_INTERVAL"] != "1h30m0s" || values["APP_LEVELS"] != "warn,error" || values["APP_AGES"] != `{"error":"90d"}` {
+		t.Errorf("unexpected values %v", values)
+	}
+	// 导出结果可原样解码
+	var decoded = new(encodeConfig)
+	if err = NewEnvDecoder(UpperCase).SetPrefix("app").SetLookup(MapLookup(values)).Marshal(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, config) {
+		t.Errorf("round trip mismatch:\n%+v\n%+v", decoded, config)
+	}
+
+	encoder.ShowSecret(false)
+	var buffer bytes.Buffer
+	if err = encoder.Write(&buffer, config, EncodeEnv); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"APP_NAME='demo app'\n", "APP_TOKEN=******\n", "APP_RATIO=0.25\n"} {
+		if !strings.Contains(buffer.String(), line) {
+			t.Errorf("env output missing %q:\n%s", line, buffer.String())
+		}
+	}
+
+	buffer.Reset()
+	if err = encoder.Write(&buffer, config, EncodeYaml); err != nil {
+		t.Fatal(err)
+	}
+	var expect = `name: demo app
+layout: "%s-${env}.log"
+interval: 1h30m0s
+levels:
+  - warn
+  - error
+ages:
+  error: 90d
+count: 3
+token: "******"
+data:
+  ratio: 0.25
+  "on": true
+`
+	if buffer.String() != expect {
+		t.Errorf("unexpected yaml:\n%s", buffer.String())
+	}
+
+	buffer.Reset()
+	if err = encoder.Write(&buffer, config, EncodeJson); err != nil {
+		t.Fatal(err)
+	}
+	var object map[string]interface{}
+	if err = json.Unmarshal(buffer.Bytes(), &object); err != nil {
+		t.Fatal(err)
+	}
+	if object["token"] != secretMask || object["data"].(map[string]interface{})["ratio"] != 0.25 {
+		t.Errorf("unexpected json %s", buffer.String())
+	}
+
+	buffer.Reset()
+	if err = encoder.Write(&buffer, config, EncodeKubernetes); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buffer.String(), "- name: APP_NAME\n  value: \"demo app\"\n") {
+		t.Errorf("unexpected k8s env:\n%s", buffer.String())
+	}
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type (
+	// envFlagBinding env 标签字段绑定的命令行参数, 参数名为环境变量名小写并以 - 分隔, eg: ROTATE_MAX_AGE -> rotate-max-age
+	envFlagBinding struct {
+		decoder *envTagDecoder
+		target  interface{}
+		flags   []*envFlag
+	}
+
+	// envFlag flag.Value 实现, 设置时按字段类型校验, Load 时写入字段
+	envFlag struct {
+		name    string
+		token   *tagToken
+		decoder *envTagDecoder
+		value   string
+		set     bool
+	}
+)
+
+// BindFlags 为 v 的每个 env 标签字段注册命令行参数, 解析参数后调用 Load 加载, 参数优先于环境变量
+func (decoder *envTagDecoder) BindFlags(set *flag.FlagSet, v interface{}) (*envFlagBinding, error) {
+	if set == nil {
+		return nil, errors.New("flag set missing")
+	}
+	var tokens = decoder.parse(v)
+	if len(tokens) <= 0 {
+		return nil, errors.New("tag parse failed")
+	}
+	var binding = &envFlagBinding{decoder: decoder, target: v}
+	for _, token := range tokens {
+		if token == nil || token.Key == "" || token.value == nil {
+			continue
+		}
+		if token.err != nil {
+			return nil, decoder.fieldError(token, decoder.make(token.Key), "", token.err)
+		}
+		var item = &envFlag{
+			name:    FlagName(decoder.make(token.Key)),
+			token:   token,
+			decoder: decoder,
+			value:   token.Default,
+		}
+		if set.Lookup(item.name) != nil {
+			return nil, fmt.Errorf("flag %s already defined", item.name)
+		}
+		set.Var(item, item.name, item.usage(decoder.make(token.Key)))
+		binding.flags = append(binding.flags, item)
+	}
+	return binding, nil
+}
+
+// FlagName 环境变量名转换为参数名, eg: ROTATE_MAX_AGE -> rotate-max-age
+func FlagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// Load 依次加载标签默认值, 环境变量, 命令行参数; 被参数覆盖的字段忽略其环境变量错误
+func (binding *envFlagBinding) Load() error {
+	var (
+		errs       EnvErrors
+		overridden = make(map[string]bool)
+	)
+	for _, item := range binding.flags {
+		if item.set {
+			overridden[binding.decoder.make(item.token.Key)] = true
+		}
+	}
+	if err := binding.decoder.Marshal(binding.target); err != nil {
+		var envErrs, ok = err.(EnvErrors)
+		if !ok {
+			return err
+		}
+		for _, v := range envErrs {
+			if !overridden[strings.TrimSuffix(v.Key, envFileSuffix)] {
+				errs = append(errs, v)
+			}
+		}
+	}
+	for _, item := range binding.flags {
+		if !item.set {
+			continue
+		}
+		if err := binding.decoder.decode(item.token, item.value); err != nil {
+			errs = append(errs, &EnvFieldError{Key: "-" + item.name, Value: item.value, Type: item.token.value.Type().String(), Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Overrides 已设置的参数, 字段路径 -> 参数值, 可作为 ConfigLoader.Override 的输入
+func (binding *envFlagBinding) Overrides() map[string]string {
+	var overrides = make(map[string]string)
+	for _, item := range binding.flags {
+		if item.set {
+			overrides[item.token.Field] = item.value
+		}
+	}
+	return overrides
+}
+
+func (item *envFlag) String() string {
+	if item == nil {
+		return ""
+	}
+	return item.value
+}
+
+// Set 按字段类型解码到临时值校验, 非法值在参数解析时报错
+func (item *envFlag) Set(value string) error {
+	var scratch = reflect.New(item.token.value.Type()).Elem()
+	if err := item.decoder.set(&scratch, value); err != nil {
+		return err
+	}
+	if err := item.token.rules.check(&scratch, value); err != nil {
+		return err
+	}
+	item.value, item.set = value, true
+	return nil
+}
+
+// IsBoolFlag 布尔字段支持 -name 简写
+func (item *envFlag) IsBoolFlag() bool {
+	return item.token.value.Kind() == reflect.Bool
+}
+
+func (item *envFlag) usage(key string) string {
+	var usage = item.token.desc
+	if usage == "" {
+		usage = item.token.Field
+	}
+	return fmt.Sprintf("%s (env %s)", usage, key)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type flagConfig struct {
+	MaxAge   time.Duration `env:"max_age,24h" desc:"保留时长"`
+	Level    string        `env:"level,warn,oneof=debug|info|warn|error"`
+	Compress bool          `env:"compress,false"`
+	Count    int           `env:"count,20"`
+	Name     string        `env:"name,app"`
+}
+
+func TestEnvDecoder_BindFlags(t *testing.T) {
+	var (
+		set     = flag.NewFlagSet("test", flag.ContinueOnError)
+		config  = new(flagConfig)
+		decoder = NewEnvDecoder().SetPrefix("rotate")
+	)
+	set.SetOutput(ioutil.Discard)
+	var binding, err = decoder.BindFlags(set, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := set.Lookup("rotate-max-age"); item == nil || item.DefValue != "24h" || !strings.Contains(item.Usage, "ROTATE_MAX_AGE") {
+		t.Fatalf("unexpected flag %+v", item)
+	}
+	_ = os.Setenv("ROTATE_LEVEL", "info")
+	_ = os.Setenv("ROTATE_COUNT", "ten")
+	_ = os.Setenv("ROTATE_NAME", "env")
+	defer func() {
+		for _, k := range []string{"ROTATE_LEVEL", "ROTATE_COUNT", "ROTATE_NAME"} {
+			_ = os.Unsetenv(k)
+		}
+	}()
+	if err = set.Parse([]string{"--rotate-max-age=72h", "-rotate-level", "error", "--rotate-compress", "--rotate-count=5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = binding.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxAge != 72*time.Hour || config.Level != "error" || !config.Compress || config.Count != 5 || config.Name != "env" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	var overrides = binding.Overrides()
+	if len(overrides) != 4 || overrides["MaxAge"] != "72h" {
+		t.Errorf("unexpected overrides %v", overrides)
+	}
+	if err = set.Parse([]string{"--rotate-level=loud"}); err == nil {
+		t.Error("expect invalid level flag error")
+	}
+	if _, err = decoder.BindFlags(set, new(flagConfig)); err == nil {
+		t.Error("expect duplicate flag error")
+	}
+}
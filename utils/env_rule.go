@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 环境变量标签选项, 位于默认值之后, eg: `env:"mode,rotate,oneof=rotate|reopen"`, `env:"url,,required,regex=^https?://"`
+// 选项以逗号分隔, 因此默认值与正则中不能包含逗号
+const (
+	tagRequired = "required"
+	tagMin      = "min"
+	tagMax      = "max"
+	tagOneOf    = "oneof"
+	tagRegex    = "regex"
+)
+
+type (
+	// EnvFieldError 单个环境变量解析/校验失败: 变量名, 原始值, 目标类型
+	EnvFieldError struct {
+		Key   string
+		Value string
+		Type  string
+		Err   error
+	}
+
+	// EnvErrors 全部字段的解析/校验错误
+	EnvErrors []*EnvFieldError
+
+	// envRules 标签选项校验规则
+	envRules struct {
+		required bool
+		min      string
+		max      string
+		oneOf    []string
+		pattern  *regexp.Regexp
+	}
+)
+
+var (
+	errEnvRequired = errors.New("value is required")
+)
+
+func (err *EnvFieldError) Error() string {
+	return fmt.Sprintf("env %s=%q (%s): %v", err.Key, err.Value, err.Type, err.Err)
+}
+
+func (err *EnvFieldError) Unwrap() error {
+	return err.Err
+}
+
+func (errs EnvErrors) Error() string {
+	var messages = make([]string, 0, len(errs))
+	for _, v := range errs {
+		messages = append(messages, v.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// parseRules 解析标签选项, 未知选项或非法正则返回错误
+func parseRules(options []string) (*envRules, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	var rules = new(envRules)
+	for _, option := range options {
+		var name, arg, hasArg = cutOption(option)
+		switch name {
+		case "":
+			continue
+		case tagRequired:
+			rules.required = true
+			continue
+		}
+		if !hasArg || arg == "" {
+			return nil, fmt.Errorf("tag option %q missing value", name)
+		}
+		switch name {
+		case tagMin:
+			rules.min = arg
+		case tagMax:
+			rules.max = arg
+		case tagOneOf:
+			rules.oneOf = strings.Split(arg, "|")
+		case tagRegex:
+			var pattern, err = regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("tag option regex: %v", err)
+			}
+			rules.pattern = pattern
+		default:
+			return nil, fmt.Errorf("unknown tag option %q", name)
+		}
+	}
+	return rules, nil
+}
+
+func cutOption(option string) (string, string, bool) {
+	option = strings.TrimSpace(option)
+	if i := strings.Index(option, "="); i >= 0 {
+		return strings.ToLower(strings.TrimSpace(option[:i])), strings.TrimSpace(option[i+1:]), true
+	}
+	return strings.ToLower(option), "", false
+}
+
+// check 校验解码后的值, data 为原始值
+func (rules *envRules) check(value *reflect.Value, data string) error {
+	if rules == nil {
+		return nil
+	}
+	if len(rules.oneOf) > 0 && !rules.in(data) {
+		return fmt.Errorf("expect one of %s", strings.Join(rules.oneOf, ", "))
+	}
+	if rules.pattern != nil && !rules.pattern.MatchString(data) {
+		return fmt.Errorf("not match %s", rules.pattern.String())
+	}
+	if rules.min != "" {
+		if n, err := compareBound(value, rules.min); err != nil {
+			return err
+		} else if n < 0 {
+			return fmt.Errorf("less than min %s", rules.min)
+		}
+	}
+	if rules.max != "" {
+		if n, err := compareBound(value, rules.max); err != nil {
+			return err
+		} else if n > 0 {
+			return fmt.Errorf("greater than max %s", rules.max)
+		}
+	}
+	return nil
+}
+
+func (rules *envRules) in(data string) bool {
+	for _, v := range rules.oneOf {
+		if strings.EqualFold(strings.TrimSpace(v), data) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareBound 比较值与边界: 数值按大小, time.Duration 按时长, 字符串/切片/map 按长度
+func compareBound(value *reflect.Value, bound string) (int, error) {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		var d, err = time.ParseDuration(bound)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration bound %q", bound)
+		}
+		return compareInt(value.Int(), int64(d)), nil
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n, err = strconv.ParseInt(bound, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer bound %q", bound)
+		}
+		return compareInt(value.Int(), n), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n, err = strconv.ParseUint(bound, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid unsigned bound %q", bound)
+		}
+		switch v := value.Uint(); {
+		case v < n:
+			return -1, nil
+		case v > n:
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.Float32, reflect.Float64:
+		var n, err = strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid float bound %q", bound)
+		}
+		switch v := value.Float(); {
+		case v < n:
+			return -1, nil
+		case v > n:
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		var n, err = strconv.Atoi(bound)
+		if err != nil {
+			return 0, fmt.Errorf("invalid length bound %q", bound)
+		}
+		var length = value.Len()
+		if value.Kind() == reflect.String {
+			length = utf8.RuneCountInString(value.String())
+		}
+		return compareInt(int64(length), int64(n)), nil
+	}
+	return 0, fmt.Errorf("min/max unsupported for %s", value.Type())
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -1,13 +1,14 @@
 package utils
 
 import (
-		"os"
-		"testing"
-		"time"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
 )
 
 type (
-
 	user struct {
 		User string `json:"user" env:"user_name"`
 		Sex  uint   `json:"sex" env:"user_sex"`
@@ -26,7 +27,7 @@ type (
 	}
 
 	Data struct {
-		Code uint    `json:"code" env:"data_code"`
+		Code uint   `json:"code" env:"data_code"`
 		Msg  string `json:"msg" env:"data_msg"`
 		Info info   `json:"info"`
 	}
@@ -41,23 +42,22 @@ type (
 		User     user                   `json:"user"`
 		Data     *Data                  `json:"data"`
 	}
-
 )
 
-func  initTestData()  {
-		_ = os.Setenv("NAME", "test")
-		_ = os.Setenv("PASSWORD", "test1111")
-		_ = os.Setenv("NUMBER", "11")
-		_ = os.Setenv("BOOLEAN", "true")
-		_ = os.Setenv("ARR", "[1,1,1]")
-		_ = os.Setenv("USER_NAME", "env")
-		_ = os.Setenv("USER_SEX", "1")
-		_ = os.Setenv("DATA_CODE", "200")
-		_ = os.Setenv("DATA_MSG", "OK")
-		_ = os.Setenv("ID", "20")
-		_ = os.Setenv("CREATE_AT", "2006-01-02 15:04:05")
-		_ = os.Setenv("AVATAR", "http://127.0.0.1/image.png")
-		_ = os.Setenv("MAPS", `{"name":"123","num":1,"bool":true,"nil":null}`)
+func initTestData() {
+	_ = os.Setenv("NAME", "test")
+	_ = os.Setenv("PASSWORD", "test1111")
+	_ = os.Setenv("NUMBER", "11")
+	_ = os.Setenv("BOOLEAN", "true")
+	_ = os.Setenv("ARR", "[1,1,1]")
+	_ = os.Setenv("USER_NAME", "env")
+	_ = os.Setenv("USER_SEX", "1")
+	_ = os.Setenv("DATA_CODE", "200")
+	_ = os.Setenv("DATA_MSG", "OK")
+	_ = os.Setenv("ID", "20")
+	_ = os.Setenv("CREATE_AT", "2006-01-02 15:04:05")
+	_ = os.Setenv("AVATAR", "http://127.0.0.1/image.png")
+	_ = os.Setenv("MAPS", `{"name":"123","num":1,"bool":true,"nil":null}`)
 }
 
 func TestEnvTagLoader_Marshal(t *testing.T) {
@@ -109,3 +109,146 @@ func TestEnvTagLoader_Marshal(t *testing.T) {
 		t.Error("解析环境变量 时间类型失败")
 	}
 }
+
+type ruleEnv struct {
+	Port    int           `env:"rule_port,8080,min=1,max=65535"`
+	Mode    string        `env:"rule_mode,rotate,oneof=rotate|reopen"`
+	Url     string        `env:"rule_url,,required,regex=^https?://"`
+	Timeout time.Duration `env:"rule_timeout,1s,max=1m"`
+	Tags    []string      `env:"rule_tags,,max=2"`
+	Ratio   float64       `env:"rule_ratio,0.5"`
+}
+
+func TestEnvTagLoader_Errors(t *testing.T) {
+	var (
+		loader = NewEnvDecoder()
+		env    = new(ruleEnv)
+	)
+	_ = os.Setenv("RULE_PORT", "70000")
+	_ = os.Setenv("RULE_MODE", "Reopen")
+	_ = os.Setenv("RULE_TIMEOUT", "2m")
+	_ = os.Setenv("RULE_TAGS", "a, b, c")
+	_ = os.Setenv("RULE_RATIO", "half")
+	defer func() {
+		for _, k := range []string{"RULE_PORT", "RULE_MODE", "RULE_TIMEOUT", "RULE_TAGS", "RULE_RATIO"} {
+			_ = os.Unsetenv(k)
+		}
+	}()
+	var err = loader.Marshal(env)
+	errs, ok := err.(EnvErrors)
+	if !ok {
+		t.Fatalf("expect EnvErrors, got %v", err)
+	}
+	var failed = make(map[string]*EnvFieldError)
+	for _, v := range errs {
+		failed[v.Key] = v
+	}
+	if len(failed) != 5 {
+		t.Errorf("expect 5 field errors, got %v", err)
+	}
+	for _, key := range []string{"RULE_PORT", "RULE_URL", "RULE_TIMEOUT", "RULE_TAGS", "RULE_RATIO"} {
+		if failed[key] == nil {
+			t.Errorf("missing error of %s: %v", key, err)
+		}
+	}
+	if v := failed["RULE_RATIO"]; v != nil && (v.Value != "half" || v.Type != "float64") {
+		t.Errorf("unexpected field error %v", v)
+	}
+	if env.Mode != "Reopen" {
+		t.Errorf("one of match should be case insensitive, got %q", env.Mode)
+	}
+	if len(env.Tags) != 3 || env.Tags[1] != "b" {
+		t.Errorf("comma separated list decode failed: %v", env.Tags)
+	}
+
+	_ = os.Setenv("RULE_PORT", "9000")
+	_ = os.Setenv("RULE_URL", "https://127.0.0.1")
+	_ = os.Setenv("RULE_TIMEOUT", "30s")
+	_ = os.Setenv("RULE_TAGS", `["a","b"]`)
+	_ = os.Setenv("RULE_RATIO", "0.25")
+	defer os.Unsetenv("RULE_URL")
+	env = new(ruleEnv)
+	if err = loader.Marshal(env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Port != 9000 || env.Timeout != 30*time.Second || env.Ratio != 0.25 {
+		t.Errorf("unexpected values %+v", env)
+	}
+}
+
+func TestEnvTagLoader_InvalidTag(t *testing.T) {
+	var env = new(struct {
+		Name string `env:"rule_name,,unknown"`
+		Code string `env:"rule_code,,regex=("`
+	})
+	var errs, ok = NewEnvDecoder().Marshal(env).(EnvErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expect 2 tag errors, got %v", errs)
+	}
+}
+
+func TestEnvTagLoader_SecretFile(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		file = filepath.Join(dir, "token")
+		env  = new(struct {
+			Token string `env:"secret_token"`
+			Url   string `env:"secret_url"`
+			Home  string `env:"secret_home,${SECRET_BASE}/home"`
+			Port  int    `env:"secret_port"`
+		})
+	)
+	if err := ioutil.WriteFile(file, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Setenv("SECRET_TOKEN_FILE", file)
+	_ = os.Setenv("SECRET_HOST", "127.0.0.1")
+	_ = os.Setenv("SECRET_BASE", "/data")
+	_ = os.Setenv("SECRET_URL", "http://${SECRET_HOST}:${SECRET_MISSING}8080/$path?t=$${SECRET_HOST}")
+	defer func() {
+		for _, k := range []string{"SECRET_TOKEN_FILE", "SECRET_HOST", "SECRET_BASE", "SECRET_URL", "SECRET_PORT_FILE"} {
+			_ = os.Unsetenv(k)
+		}
+	}()
+	if err := NewEnvDecoder().Marshal(env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Token != "s3cr3t" {
+		t.Errorf("secret file value expect s3cr3t, got %q", env.Token)
+	}
+	if env.Url != "http://127.0.0.1:8080/$path?t=${SECRET_HOST}" {
+		t.Errorf("unexpected expanded url %q", env.Url)
+	}
+	if env.Home != "/data/home" {
+		t.Errorf("unexpected expanded default %q", env.Home)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("not a number\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Setenv("SECRET_PORT_FILE", file)
+	var errs, ok = NewEnvDecoder().Marshal(env).(EnvErrors)
+	if !ok || len(errs) != 1 || errs[0].Key != "SECRET_PORT_FILE" || errs[0].Value != file {
+		t.Fatalf("expect file path in error, got %v", errs)
+	}
+}
+
+func TestEnvTagLoader_DebugKey(t *testing.T) {
+	var cases = []struct {
+		prefix, suffix, expect string
+	}{
+		{"app", "", "APP_LOG_NAME"},
+		{"app_", "", "APP__LOG_NAME"},
+		{"", "logger", "LOG_NAME_LOGGER"},
+		{"app", "logger", "APP_LOG_NAME_LOGGER"},
+	}
+	for _, c := range cases {
+		var decoder = NewEnvDecoder(UpperCase).SetPrefix(c.prefix)
+		if c.suffix != "" {
+			decoder.SetSuffix(c.suffix)
+		}
+		if key := decoder.DebugKey("log_name"); key != c.expect {
+			t.Errorf("prefix %q suffix %q => %s, expect %s", c.prefix, c.suffix, key, c.expect)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"sync"
+)
+
+type (
+	// EnvDecodeFunc 自定义类型解码, 返回值需可赋值给注册类型
+	EnvDecodeFunc func(data string) (interface{}, error)
+
+	envTypeRegistry struct {
+		locker   sync.RWMutex
+		decoders map[reflect.Type]EnvDecodeFunc
+	}
+)
+
+var (
+	envTypes = &envTypeRegistry{decoders: make(map[reflect.Type]EnvDecodeFunc)}
+)
+
+func init() {
+	RegisterEnvDecoder(url.URL{}, func(data string) (interface{}, error) {
+		var u, err = url.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		return *u, nil
+	})
+}
+
+// RegisterEnvDecoder 注册类型解码, sample 为该类型的零值, eg: RegisterEnvDecoder(Level(0), parseLevel);
+// 优先于内置解码, 注册值类型后指针字段自动分配
+func RegisterEnvDecoder(sample interface{}, decode EnvDecodeFunc) {
+	if sample == nil || decode == nil {
+		return
+	}
+	envTypes.locker.Lock()
+	defer envTypes.locker.Unlock()
+	envTypes.decoders[reflect.TypeOf(sample)] = decode
+}
+
+func (registry *envTypeRegistry) get(typ reflect.Type) (EnvDecodeFunc, bool) {
+	registry.locker.RLock()
+	defer registry.locker.RUnlock()
+	var decode, ok = registry.decoders[typ]
+	return decode, ok
+}
+
+// decode 按注册解码器设置值, 未注册时返回 false
+func (registry *envTypeRegistry) decode(value *reflect.Value, data string) (bool, error) {
+	var decode, ok = registry.get(value.Type())
+	if !ok {
+		return false, nil
+	}
+	var result, err = decode(data)
+	if err != nil {
+		return true, err
+	}
+	var rv = reflect.ValueOf(result)
+	if !rv.IsValid() {
+		value.Set(reflect.Zero(value.Type()))
+		return true, nil
+	}
+	if !rv.Type().AssignableTo(value.Type()) {
+		if !rv.Type().ConvertibleTo(value.Type()) {
+			return true, errors.New("decoder result " + rv.Type().String() + " is not assignable to " + value.Type().String())
+		}
+		rv = rv.Convert(value.Type())
+	}
+	value.Set(rv)
+	return true, nil
+}
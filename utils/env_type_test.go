@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	envSize int64
+
+	typedEnv struct {
+		Port   *int        `env:"port"`
+		Name   *string     `env:"name"`
+		Unset  *int        `env:"unset"`
+		Ip     net.IP      `env:"ip"`
+		Proxy  *url.URL    `env:"proxy"`
+		Home   url.URL     `env:"home"`
+		Level  log.Level   `env:"level,info"`
+		Levels []log.Level `env:"levels"`
+		Size   envSize     `env:"size"`
+		Limit  *envSize    `env:"limit"`
+		Hosts  []net.IP    `env:"hosts"`
+		Policy *log.Level  `env:"policy"`
+		Bad    []log.Level `env:"bad"`
+	}
+)
+
+func init() {
+	RegisterEnvDecoder(envSize(0), func(data string) (interface{}, error) {
+		var n = int64(1)
+		switch {
+		case strings.HasSuffix(data, "KB"):
+			n, data = 1<<10, strings.TrimSuffix(data, "KB")
+		case strings.HasSuffix(data, "MB"):
+			n, data = 1<<20, strings.TrimSuffix(data, "MB")
+		}
+		var value int64
+		for _, c := range data {
+			if c < '0' || c > '9' {
+				return nil, errors.New("invalid size")
+			}
+			value = value*10 + int64(c-'0')
+		}
+		return envSize(value * n), nil
+	})
+}
+
+func TestEnvDecoder_Types(t *testing.T) {
+	var values = map[string]string{
+		"PORT":   "8080",
+		"NAME":   "demo",
+		"IP":     "10.0.0.1",
+		"PROXY":  "http://127.0.0.1:3128",
+		"HOME":   "https://example.com/home",
+		"LEVEL":  "warning",
+		"LEVELS": "warn, error",
+		"SIZE":   "2MB",
+		"LIMIT":  "4KB",
+		"HOSTS":  "10.0.0.1,10.0.0.2",
+		"POLICY": "error",
+		"BAD":    "warn,loud",
+	}
+	var (
+		env  = new(typedEnv)
+		err  = NewEnvDecoder().SetLookup(MapLookup(values)).Marshal(env)
+		errs EnvErrors
+		ok   bool
+	)
+	if errs, ok = err.(EnvErrors); !ok || len(errs) != 1 || errs[0].Key != "BAD" {
+		t.Fatalf("expect only BAD to fail, got %v", err)
+	}
+	if env.Port == nil || *env.Port != 8080 || env.Name == nil || *env.Name != "demo" || env.Unset != nil {
+		t.Errorf("unexpected pointers %v %v %v", env.Port, env.Name, env.Unset)
+	}
+	if !env.Ip.Equal(net.ParseIP("10.0.0.1")) || len(env.Hosts) != 2 || !env.Hosts[1].Equal(net.ParseIP("10.0.0.2")) {
+		t.Errorf("unexpected ip %v %v", env.Ip, env.Hosts)
+	}
+	if env.Proxy == nil || env.Proxy.Host != "127.0.0.1:3128" || env.Home.Path != "/home" {
+		t.Errorf("unexpected url %v %v", env.Proxy, env.Home)
+	}
+	if env.Level != log.WarnLevel || len(env.Levels) != 2 || env.Levels[1] != log.ErrorLevel {
+		t.Errorf("unexpected level %v %v", env.Level, env.Levels)
+	}
+	if env.Policy == nil || *env.Policy != log.ErrorLevel {
+		t.Errorf("unexpected level pointer %v", env.Policy)
+	}
+	if env.Size != 2<<20 || env.Limit == nil || *env.Limit != 4<<10 {
+		t.Errorf("unexpected custom type %v %v", env.Size, env.Limit)
+	}
+
+	values["IP"] = "10.0.0"
+	values["SIZE"] = "2GB"
+	delete(values, "BAD")
+	if errs, ok = NewEnvDecoder().SetLookup(MapLookup(values)).Marshal(new(typedEnv)).(EnvErrors); !ok || len(errs) != 2 {
+		t.Errorf("expect ip and size errors, got %v", errs)
+	}
+}